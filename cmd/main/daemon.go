@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"tgdump/internal/backup"
+	"tgdump/internal/config"
+	"tgdump/internal/scheduler"
+)
+
+// daemon выполняет задания и при остановке ждёт текущие запуски не дольше
+// grace, после чего отменяет их.
+type daemon struct {
+	runCtx context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	stopping bool
+	running  sync.WaitGroup
+}
+
+func newDaemon() *daemon {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &daemon{runCtx: ctx, cancel: cancel}
+}
+
+// runJob выполняет задание и пишет итог в лог. Ошибки не останавливают
+// планировщик: подробности уже отправлены в отчёте.
+func (d *daemon) runJob(job *config.Config) {
+	d.mu.Lock()
+	if d.stopping {
+		d.mu.Unlock()
+		return
+	}
+	d.running.Add(1)
+	d.mu.Unlock()
+	defer d.running.Done()
+
+	err := backup.Run(d.runCtx, job)
+	switch {
+	case errors.Is(err, backup.ErrSkipped):
+		log.Printf("%s%v", jobLogPrefix(job), err)
+	case err != nil:
+		log.Printf("%sошибка при выполнении резервного копирования: %v", jobLogPrefix(job), err)
+	default:
+		log.Printf("%sрезервное копирование выполнено успешно", jobLogPrefix(job))
+	}
+}
+
+// cancelTimeout — сколько ждать запуск после отмены: остановка pg_dump
+// (до 10 с), удаление временной базы и уведомление об ошибке (до 30 с
+// каждое). Вместе с shutdown_grace должно укладываться в stop_grace_period
+// контейнера.
+const cancelTimeout = 75 * time.Second
+
+// shutdown запрещает новые запуски и ждёт текущие. По истечении grace
+// запуски отменяются: дочерние процессы останавливаются, рабочие каталоги
+// удаляются.
+func (d *daemon) shutdown(grace time.Duration) {
+	d.mu.Lock()
+	d.stopping = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grace):
+		log.Printf("текущий запуск не завершился за %s, прерывание", grace)
+		d.cancel()
+		select {
+		case <-done:
+		case <-time.After(cancelTimeout):
+			log.Printf("запуск не завершился за %s после прерывания, выход", cancelTimeout)
+		}
+	}
+	d.cancel()
+}
+
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml")
+	var sel selectionFlags
+	sel.register(fs)
+	noDelivery := fs.Bool("no-delivery", false, "ничего не отправлять в Telegram")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump daemon [флаги]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cfg, jobs, err := loadJobs(*configPath, sel.selection(), *noDelivery)
+	if err != nil {
+		return err
+	}
+	cfg.Print()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	d := newDaemon()
+	// Расписания регистрируются до запусков при старте, а сами запуски идут
+	// параллельно: долгий запуск одного задания не задерживает остальные.
+	for _, job := range jobs {
+		if _, err := scheduler.Start(job.Schedule, job.Location(), func() { d.runJob(job) }); err != nil {
+			return fmt.Errorf("%s%w", jobLogPrefix(job), err)
+		}
+	}
+	for _, job := range jobs {
+		go func() {
+			run, reason, err := shouldRunOnStart(job, time.Now())
+			if err != nil {
+				// Лучше лишний запуск, чем пропущенный.
+				run, reason = true, fmt.Sprintf("не удалось проверить пропущенные запуски: %v", err)
+			}
+			log.Printf("%sзапуск при старте: %s", jobLogPrefix(job), reason)
+			if run {
+				d.runJob(job)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	log.Printf("получен сигнал остановки, ожидание текущих запусков до %s", cfg.ShutdownGrace)
+	d.shutdown(cfg.ShutdownGrace)
+	log.Printf("остановлено")
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"tgdump/internal/config"
+	"tgdump/internal/crypt"
+)
+
+func runDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml (пароль из encryption.passphrase)")
+	identity := fs.String("identity", "", "файл с приватными ключами age")
+	output := fs.String("o", "", "куда сохранить результат (по умолчанию имя без .age)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump decrypt [флаги] <archive.zip.age>")
+		fmt.Fprintln(fs.Output(), "пароль также читается из переменной TGDUMP_PASSPHRASE")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("не указан файл")
+	}
+	src := fs.Arg(0)
+
+	dst := *output
+	if dst == "" {
+		if !strings.HasSuffix(src, crypt.Ext) {
+			return fmt.Errorf("укажите -o: у файла %s нет расширения %s", src, crypt.Ext)
+		}
+		dst = strings.TrimSuffix(src, crypt.Ext)
+	}
+
+	passphrase := os.Getenv("TGDUMP_PASSPHRASE")
+	if passphrase == "" && *identity == "" {
+		if cfg, err := config.ReadFile(*configPath); err == nil {
+			passphrase = cfg.Encryption.Passphrase
+		}
+	}
+
+	if err := crypt.DecryptFile(src, dst, *identity, passphrase); err != nil {
+		return err
+	}
+	fmt.Println(dst)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"tgdump/internal/archive"
+)
+
+func runJoin(args []string) error {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	output := fs.String("o", "", "куда сохранить результат (по умолчанию имя без .001)")
+	sum := fs.String("sha256", "", "ожидаемая сумма (по умолчанию из файла <имя>.sha256)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump join [флаги] <archive.zip.001> [другие части...]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("не указаны части")
+	}
+
+	first := fs.Arg(0)
+	base, ok := strings.CutSuffix(first, ".001")
+	if !ok {
+		return fmt.Errorf("первая часть должна оканчиваться на .001: %s", first)
+	}
+
+	parts := fs.Args()
+	if len(parts) == 1 {
+		var err error
+		if parts, err = archive.FindParts(first); err != nil {
+			return err
+		}
+	}
+
+	want := *sum
+	if want == "" {
+		var err error
+		if want, err = archive.ReadChecksum(base + archive.ChecksumExt); err != nil {
+			return fmt.Errorf("%w (укажите -sha256)", err)
+		}
+	}
+
+	dst := *output
+	if dst == "" {
+		dst = base
+	}
+	if err := archive.Join(parts, dst, want); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d частей, контрольная сумма совпадает\n", dst, len(parts))
+	return nil
+}
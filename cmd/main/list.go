@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tgdump/internal/config"
+	"tgdump/internal/retention"
+)
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml")
+	var sel selectionFlags
+	sel.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump list [флаги]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	_, jobs, err := loadJobs(*configPath, sel.selection(), false)
+	if err != nil {
+		return err
+	}
+	for i, job := range jobs {
+		if i > 0 {
+			fmt.Println()
+		}
+		name := job.JobName
+		if name == "" {
+			name = "(без имени)"
+		}
+		fmt.Printf("Задание %s, расписание: %s\n", name, strings.Join(job.Schedule, ", "))
+		for _, db := range job.Databases {
+			fmt.Printf("  база %s (%s:%s, %s) [%s]\n", db.DBName, db.Host, db.Port, db.Format, db.Delivery.Label())
+		}
+		for _, f := range job.Files {
+			fmt.Printf("  файл %s [%s]\n", f.Path, f.Delivery.Label())
+		}
+		for _, d := range job.Directories {
+			fmt.Printf("  каталог %s [%s]\n", d.Path, d.Delivery.Label())
+		}
+
+		prefix := ""
+		if job.JobName != "" {
+			prefix = job.JobName + "_"
+		}
+		archives, err := retention.List(job.DumpDir, prefix, job.Location())
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if len(archives) == 0 {
+			fmt.Println("  локальных архивов нет")
+			continue
+		}
+		fmt.Println("  локальные архивы:")
+		for _, a := range archives {
+			fmt.Printf("    %s (%.2f МБ)\n", filepath.Base(a.Path), float64(a.Size)/(1024*1024))
+		}
+	}
+	return nil
+}
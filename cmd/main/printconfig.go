@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"tgdump/internal/config"
+)
+
+func runPrintConfig(args []string) error {
+	fs := flag.NewFlagSet("print-config", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump print-config [флаги]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cfg, err := config.ReadFile(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg.Print()
+	return nil
+}
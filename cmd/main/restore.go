@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+	"tgdump/internal/restore"
+)
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml")
+	list := fs.Bool("list", false, "показать содержимое архива и выйти")
+	dbName := fs.String("db", "", "имя базы в архиве для восстановления")
+	targetDB := fs.String("target-db", "", "имя целевой базы (по умолчанию как в архиве)")
+	host := fs.String("host", "", "хост целевого сервера")
+	port := fs.String("port", "", "порт целевого сервера")
+	user := fs.String("user", "", "пользователь целевого сервера")
+	password := fs.String("password", "", "пароль (по умолчанию из config.yml или PGPASSWORD)")
+	files := fs.Bool("files", false, "вернуть файлы и каталоги в files_dir")
+	dryRun := fs.Bool("dry-run", false, "только показать действия")
+	drop := fs.Bool("drop", false, "удалить и заново создать целевую базу")
+	jobs := fs.Int("jobs", 1, "параллельные потоки pg_restore")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump restore [флаги] <archive.zip>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("не указан архив")
+	}
+	zipPath := fs.Arg(0)
+
+	if *list {
+		m, err := archive.ReadManifest(zipPath)
+		if err != nil {
+			return err
+		}
+		fmt.Print(restore.Contents(m))
+		return nil
+	}
+
+	// Конфигурация нужна для files_dir и подключения по умолчанию, но без
+	// -files её может не быть. Ошибки в существующем файле не пропускаются.
+	cfg, err := config.ReadFile(*configPath)
+	if err != nil {
+		if *files || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		cfg = &config.Config{}
+	}
+
+	target := config.DumpConfig{Host: "localhost", Port: "5432", User: "postgres"}
+	for _, db := range cfg.Databases {
+		if db.DBName == *dbName {
+			target = db
+			break
+		}
+	}
+	target.DBName = *dbName
+	if *targetDB != "" {
+		target.DBName = *targetDB
+	}
+	if *host != "" {
+		target.Host = *host
+	}
+	if *port != "" {
+		target.Port = *port
+	}
+	if *user != "" {
+		target.User = *user
+	}
+	if *password != "" {
+		target.Password = *password
+	}
+	if *drop {
+		if src, ok := sourceDatabase(cfg, target); ok {
+			return fmt.Errorf("-drop удалит базу %s на %s:%s, из которой делаются резервные копии; укажите -host или -target-db", src.DBName, src.Host, src.Port)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	return restore.Run(ctx, restore.Options{
+		Archive:  zipPath,
+		Database: *dbName,
+		Target:   target,
+		Files:    *files,
+		FilesDir: cfg.FilesDir,
+		DryRun:   *dryRun,
+		Drop:     *drop,
+		Jobs:     *jobs,
+	})
+}
+
+// sourceDatabase ищет в databases базу, совпадающую с target по хосту, порту
+// и имени.
+func sourceDatabase(cfg *config.Config, target config.DumpConfig) (config.DumpConfig, bool) {
+	for _, db := range cfg.Databases {
+		if db.DBName == target.DBName && serverAddr(db) == serverAddr(target) {
+			return db, true
+		}
+	}
+	return config.DumpConfig{}, false
+}
+
+func serverAddr(db config.DumpConfig) string {
+	host, port := db.Host, db.Port
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "5432"
+	}
+	return strings.ToLower(host) + ":" + port
+}
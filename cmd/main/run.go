@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"tgdump/internal/backup"
+	"tgdump/internal/config"
+)
+
+// runOnce выполняет выбранные задания один раз; ошибка любого задания даёт
+// ненулевой код выхода.
+func runOnce(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml")
+	var sel selectionFlags
+	sel.register(fs)
+	noDelivery := fs.Bool("no-delivery", false, "ничего не отправлять в Telegram, только локальный архив")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump run [флаги]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	_, jobs, err := loadJobs(*configPath, sel.selection(), *noDelivery)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("нечего выполнять")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	var failed int
+	for _, job := range jobs {
+		if err := backup.Run(ctx, job); err != nil {
+			log.Printf("%s%v", jobLogPrefix(job), err)
+			failed++
+			continue
+		}
+		log.Printf("%sрезервное копирование выполнено успешно", jobLogPrefix(job))
+	}
+	if failed > 0 {
+		return fmt.Errorf("заданий с ошибками: %d из %d", failed, len(jobs))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"tgdump/internal/config"
+)
+
+// listFlag — флаг, который можно повторять или перечислять через запятую.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+type selectionFlags struct {
+	jobs, databases, files, directories listFlag
+}
+
+func (s *selectionFlags) register(fs *flag.FlagSet) {
+	fs.Var(&s.jobs, "job", "только эти задания (можно повторять или через запятую)")
+	fs.Var(&s.databases, "db", "только эти базы")
+	fs.Var(&s.files, "file", "только эти файлы (path из files)")
+	fs.Var(&s.directories, "dir", "только эти каталоги (path из directories)")
+}
+
+func (s *selectionFlags) selection() config.Selection {
+	return config.Selection{
+		Jobs:        s.jobs,
+		Databases:   s.databases,
+		Files:       s.files,
+		Directories: s.directories,
+	}
+}
+
+// loadJobs читает конфигурацию и возвращает выбранные задания.
+func loadJobs(path string, sel config.Selection, noDelivery bool) (*config.Config, []*config.Config, error) {
+	cfg, err := config.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, err := cfg.ResolveJobs()
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, err = config.SelectJobs(jobs, sel)
+	if err != nil {
+		return nil, nil, err
+	}
+	if noDelivery {
+		for _, job := range jobs {
+			job.DisableDelivery()
+		}
+	}
+	return cfg, jobs, nil
+}
@@ -1,31 +1,110 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
-	"tgdump/internal/backup"
 	"tgdump/internal/config"
+	"tgdump/internal/runstate"
 	"tgdump/internal/scheduler"
 )
 
+const usage = `использование: tgdump [команда] [флаги]
+
+команды:
+  daemon        выполнять задания по расписанию (по умолчанию)
+  run           выполнить задания один раз и выйти
+  validate      проверить конфигурацию и наличие файлов
+  list          показать задания, элементы и локальные архивы
+  print-config  показать конфигурацию
+  restore       восстановить базу или файлы из архива
+  decrypt       расшифровать архив .age
+  join          собрать архив из частей
+  verify        проверить архив по манифесту
+
+справка по флагам команды: tgdump <команда> -h
+`
+
 func main() {
-	cfg, err := config.Read()
+	args := os.Args[1:]
+	cmd := "daemon"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "daemon":
+		err = runDaemon(args)
+	case "run":
+		err = runOnce(args)
+	case "validate":
+		err = runValidate(args)
+	case "list":
+		err = runList(args)
+	case "print-config":
+		err = runPrintConfig(args)
+	case "restore":
+		err = runRestore(args)
+	case "decrypt":
+		err = runDecrypt(args)
+	case "join":
+		err = runJoin(args)
+	case "verify":
+		err = runVerify(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		log.Fatalf("неизвестная команда: %s", cmd)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	cfg.Print()
+}
 
-	if err := backup.Run(cfg); err != nil {
-		log.Fatal(err)
+// signalContext возвращает контекст, отменяемый по SIGINT и SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func jobLogPrefix(job *config.Config) string {
+	if job.JobName == "" {
+		return ""
 	}
+	return "[" + job.JobName + "] "
+}
 
-	scheduler.ScheduleDailyAt(cfg.Schedule, func() {
-		if err := backup.Run(cfg); err != nil {
-			log.Printf("ошибка при выполнении резервного копирования: %v", err)
-		} else {
-			log.Printf("резервное копирование выполнено успешно")
-		}
-	})
+// shouldRunOnStart решает, выполнять ли задание при старте по run_on_start
+// и времени последнего успешного запуска.
+func shouldRunOnStart(job *config.Config, now time.Time) (bool, string, error) {
+	switch job.RunOnStart {
+	case config.RunOnStartAlways:
+		return true, "run_on_start=always", nil
+	case config.RunOnStartNever:
+		return false, "run_on_start=never", nil
+	}
 
-	select {}
+	last, ok, err := runstate.LastSuccess(job.DumpDir, job.JobName)
+	if err != nil {
+		return false, "", err
+	}
+	if !ok {
+		return true, "успешных запусков ещё не было", nil
+	}
+	missed, err := scheduler.Missed(job.Schedule, job.Location(), last, now)
+	if err != nil {
+		return false, "", err
+	}
+	if missed {
+		return true, fmt.Sprintf("пропущен запуск по расписанию, последний успешный %s", last.In(job.Location()).Format(time.DateTime)), nil
+	}
+	return false, "пропущенных запусков нет", nil
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"tgdump/internal/config"
+)
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", config.DefaultPath, "путь к config.yml")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump validate [флаги]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	// Значения и расписания проверяет ReadFile, здесь — ещё и наличие файлов.
+	cfg, jobs, err := loadJobs(*configPath, config.Selection{}, false)
+	if err != nil {
+		return err
+	}
+	if err := cfg.CheckPaths(); err != nil {
+		return err
+	}
+	fmt.Printf("конфигурация %s в порядке, заданий: %d\n", *configPath, len(jobs))
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"tgdump/internal/archive"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "использование: tgdump verify <archive.zip>")
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("не указан архив")
+	}
+
+	res, err := archive.Verify(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, name := range res.Mismatched {
+		fmt.Printf("ПОВРЕЖДЁН: %s\n", name)
+	}
+	for _, name := range res.Missing {
+		fmt.Printf("ОТСУТСТВУЕТ: %s\n", name)
+	}
+	for _, name := range res.Unexpected {
+		fmt.Printf("НЕ В МАНИФЕСТЕ: %s\n", name)
+	}
+	if !res.OK() {
+		return fmt.Errorf("архив не прошёл проверку: проверено %d, повреждено %d, отсутствует %d, лишних %d",
+			res.Checked, len(res.Mismatched), len(res.Missing), len(res.Unexpected))
+	}
+	fmt.Printf("архив в порядке: проверено %d файлов\n", res.Checked)
+	return nil
+}
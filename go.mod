@@ -3,7 +3,13 @@ module tgdump
 go 1.23.6
 
 require (
+	filippo.io/age v1.2.1
 	github.com/lib/pq v1.12.3
 	github.com/robfig/cron/v3 v3.0.1
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require (
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
@@ -0,0 +1,199 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestName — имя файла с описанием содержимого в корне архива.
+const ManifestName = "manifest.json"
+
+type Manifest struct {
+	Version     string             `json:"version"`
+	Job         string             `json:"job,omitempty"`
+	Timestamp   string             `json:"timestamp"`
+	Config      json.RawMessage    `json:"config,omitempty"` // использованные записи конфигурации без секретов
+	Databases   []ManifestDatabase `json:"databases"`
+	Files       []ManifestAsset    `json:"files"`
+	Directories []ManifestAsset    `json:"directories"`
+	SHA256      map[string]string  `json:"sha256"` // путь в архиве -> SHA-256, заполняет WriteManifest
+}
+
+// ManifestDatabase описывает дамп базы. Пути указаны относительно корня архива.
+type ManifestDatabase struct {
+	Name     string `json:"name"`
+	Format   string `json:"format"`
+	Path     string `json:"path"`
+	Filtered string `json:"filtered,omitempty"` // SQL с отфильтрованными данными для не-plain форматов
+
+	Tables []ManifestTable `json:"tables,omitempty"`
+}
+
+type ManifestTable struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	Rows   int64  `json:"rows"`
+}
+
+// ManifestAsset связывает путь из конфигурации (относительно files_dir) с путём в архиве.
+// Для инкрементальной копии каталога в архиве только изменённые файлы,
+// а Deleted перечисляет удалённые с прошлого запуска (относительно каталога).
+// Base — метка времени архива с последней полной копией каталога: для
+// восстановления нужны он и все архивы задания после него по этот.
+type ManifestAsset struct {
+	Path        string   `json:"path"`
+	Archive     string   `json:"archive"`
+	Incremental bool     `json:"incremental,omitempty"`
+	Base        string   `json:"base,omitempty"`
+	Deleted     []string `json:"deleted,omitempty"`
+}
+
+// Empty сообщает, что в манифесте нет ни одного элемента.
+func (m Manifest) Empty() bool {
+	return len(m.Databases) == 0 && len(m.Files) == 0 && len(m.Directories) == 0
+}
+
+// WriteManifest хеширует все файлы каталога dir и сохраняет манифест в его корень.
+func WriteManifest(dir string, m Manifest) error {
+	sums, err := HashDirectory(dir)
+	if err != nil {
+		return err
+	}
+	m.SHA256 = sums
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации манифеста: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), data, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи манифеста: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest читает манифест из zip-архива.
+func ReadManifest(zipPath string) (Manifest, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("ошибка открытия архива: %w", err)
+	}
+	defer r.Close()
+
+	f, err := r.Open(ManifestName)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("в архиве нет %s: %w", ManifestName, err)
+	}
+	defer f.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("ошибка чтения манифеста: %w", err)
+	}
+	return m, nil
+}
+
+// HashDirectory считает SHA-256 всех файлов каталога, кроме манифеста.
+// Ключи — пути относительно dir с разделителем "/", как в zip.
+func HashDirectory(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ManifestName {
+			return nil
+		}
+		sum, err := SHA256File(path)
+		if err != nil {
+			return err
+		}
+		sums[rel] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта контрольных сумм: %w", err)
+	}
+	return sums, nil
+}
+
+type VerifyResult struct {
+	Checked    int
+	Missing    []string // есть в манифесте, нет в архиве
+	Unexpected []string // есть в архиве, нет в манифесте
+	Mismatched []string // сумма не совпадает или запись не читается
+}
+
+func (v VerifyResult) OK() bool {
+	return len(v.Missing) == 0 && len(v.Unexpected) == 0 && len(v.Mismatched) == 0
+}
+
+// Verify пересчитывает SHA-256 каждой записи архива и сверяет с манифестом.
+func Verify(zipPath string) (VerifyResult, error) {
+	m, err := ReadManifest(zipPath)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	if m.SHA256 == nil {
+		return VerifyResult{}, fmt.Errorf("в манифесте нет контрольных сумм")
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("ошибка открытия архива: %w", err)
+	}
+	defer r.Close()
+
+	var res VerifyResult
+	seen := make(map[string]struct{}, len(m.SHA256))
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || f.Name == ManifestName {
+			continue
+		}
+		seen[f.Name] = struct{}{}
+		want, ok := m.SHA256[f.Name]
+		if !ok {
+			res.Unexpected = append(res.Unexpected, f.Name)
+			continue
+		}
+		res.Checked++
+		got, err := hashZipEntry(f)
+		if err != nil || got != want {
+			res.Mismatched = append(res.Mismatched, f.Name)
+		}
+	}
+	for name := range m.SHA256 {
+		if _, ok := seen[name]; !ok {
+			res.Missing = append(res.Missing, name)
+		}
+	}
+	sort.Strings(res.Missing)
+	return res, nil
+}
+
+func hashZipEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
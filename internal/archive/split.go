@@ -0,0 +1,169 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumExt — расширение файла с SHA-256 исходного файла в формате sha256sum.
+const ChecksumExt = ".sha256"
+
+// SHA256File возвращает SHA-256 файла в hex.
+func SHA256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("ошибка чтения %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteChecksum пишет path+".sha256" с суммой файла и возвращает путь к нему.
+func WriteChecksum(path, sum string) (string, error) {
+	checksumPath := path + ChecksumExt
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	if err := os.WriteFile(checksumPath, []byte(line), 0o644); err != nil {
+		return "", fmt.Errorf("ошибка записи контрольной суммы: %w", err)
+	}
+	return checksumPath, nil
+}
+
+// ReadChecksum читает сумму из файла в формате sha256sum.
+func ReadChecksum(checksumPath string) (string, error) {
+	data, err := os.ReadFile(checksumPath)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения контрольной суммы: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("пустой файл контрольной суммы %s", checksumPath)
+	}
+	return fields[0], nil
+}
+
+// PartName возвращает имя части n (с единицы): archive.zip.001.
+func PartName(path string, n int) string {
+	return fmt.Sprintf("%s.%03d", path, n)
+}
+
+// Split режет файл на части не больше partSize байт рядом с исходным файлом.
+// Отмена ctx проверяется при чтении каждого блока.
+func Split(ctx context.Context, path string, partSize int64) ([]string, error) {
+	if partSize <= 0 {
+		return nil, fmt.Errorf("некорректный размер части: %d", partSize)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer in.Close()
+
+	var parts []string
+	for n := 1; ; n++ {
+		partPath := PartName(path, n)
+		out, err := os.Create(partPath)
+		if err != nil {
+			return parts, fmt.Errorf("ошибка создания части %s: %w", partPath, err)
+		}
+		written, err := io.CopyN(out, contextReader{ctx, in}, partSize)
+		closeErr := out.Close()
+		if err != nil && err != io.EOF {
+			_ = os.Remove(partPath)
+			return parts, fmt.Errorf("ошибка записи части %s: %w", partPath, err)
+		}
+		if closeErr != nil {
+			_ = os.Remove(partPath)
+			return parts, closeErr
+		}
+		if written == 0 {
+			_ = os.Remove(partPath)
+			break
+		}
+		parts = append(parts, partPath)
+		if err == io.EOF {
+			break
+		}
+	}
+	return parts, nil
+}
+
+// FindParts находит части .001, .002, ... по пути первой части.
+func FindParts(firstPart string) ([]string, error) {
+	base, ok := strings.CutSuffix(firstPart, ".001")
+	if !ok {
+		return nil, fmt.Errorf("ожидается первая часть с суффиксом .001: %s", firstPart)
+	}
+	var parts []string
+	for n := 1; ; n++ {
+		p := PartName(base, n)
+		if _, err := os.Stat(p); err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// Join склеивает части в dst и сверяет SHA-256 результата с wantSum.
+func Join(parts []string, dst, wantSum string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла %s: %w", dst, err)
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	w := io.MultiWriter(out, h)
+	for _, part := range parts {
+		if err := appendFile(w, part); err != nil {
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, wantSum) {
+		return fmt.Errorf("контрольная сумма не совпадает: ожидалось %s, получено %s", wantSum, got)
+	}
+	return nil
+}
+
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия части %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("ошибка чтения части %s: %w", path, err)
+	}
+	return nil
+}
+
+// contextReader прерывает чтение после отмены ctx.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
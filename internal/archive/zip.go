@@ -2,26 +2,40 @@ package archive
 
 import (
 	"archive/zip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-func ZipDirectory(dir string) (string, error) {
-	zipPath := dir + ".zip"
+// ZipDirectory упаковывает каталог dir в dir+".zip". При ошибке или отмене
+// ctx недописанный архив удаляется.
+func ZipDirectory(ctx context.Context, dir string) (zipPath string, err error) {
+	zipPath = dir + ".zip"
 
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
 		return "", fmt.Errorf("ошибка создания архива: %w", err)
 	}
 	defer zipFile.Close()
+	defer func() {
+		if err != nil {
+			zipFile.Close()
+			_ = os.Remove(zipPath)
+		}
+	}()
 
 	zipWriter := zip.NewWriter(zipFile)
 	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return fmt.Errorf("ошибка обхода %s: %w", path, err)
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
@@ -59,3 +73,65 @@ func addFileToZip(zipWriter *zip.Writer, baseDir, path string) error {
 	}
 	return nil
 }
+
+var ErrNotInArchive = errors.New("в архиве нет записи")
+
+// Extract распаковывает запись src архива (файл или каталог) в путь dst.
+// Возвращает число извлечённых файлов.
+func Extract(zipPath, src, dst string) (int, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка открытия архива: %w", err)
+	}
+	defer r.Close()
+
+	src = strings.TrimSuffix(src, "/")
+	var count int
+	for _, f := range r.File {
+		var rel string
+		switch {
+		case f.Name == src:
+		case strings.HasPrefix(f.Name, src+"/"):
+			rel = strings.TrimPrefix(f.Name, src+"/")
+		default:
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(rel)) && rel != "" {
+			return count, fmt.Errorf("недопустимый путь в архиве: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := extractFile(f, target); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNotInArchive, src)
+	}
+	return count, nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога для %s: %w", target, err)
+	}
+	in, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("ошибка чтения %s из архива: %w", f.Name, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла %s: %w", target, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("ошибка распаковки %s: %w", f.Name, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipExtractRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "run")
+	if err := os.MkdirAll(filepath.Join(src, "uploads", "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "uploads", "a", "x.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.db"), []byte("db"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := Manifest{
+		Timestamp:   "t",
+		Files:       []ManifestAsset{{Path: "data/main.db", Archive: "main.db"}},
+		Directories: []ManifestAsset{{Path: "uploads", Archive: "uploads"}},
+	}
+	if err := WriteManifest(src, m); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath, err := ZipDirectory(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadManifest(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Timestamp != "t" || len(got.Files) != 1 || len(got.Directories) != 1 {
+		t.Fatalf("manifest: %+v", got)
+	}
+
+	dst := filepath.Join(root, "out")
+	if n, err := Extract(zipPath, "uploads", filepath.Join(dst, "uploads")); err != nil || n != 1 {
+		t.Fatalf("extract dir: %d %v", n, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "uploads", "a", "x.txt")); err != nil || string(data) != "x" {
+		t.Fatalf("extracted dir file: %q %v", data, err)
+	}
+	if _, err := Extract(zipPath, "main.db", filepath.Join(dst, "data", "main.db")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "data", "main.db")); err != nil || string(data) != "db" {
+		t.Fatalf("extracted file: %q %v", data, err)
+	}
+	if _, err := Extract(zipPath, "missing", dst); err == nil {
+		t.Fatal("expected error for missing entry")
+	}
+
+	res, err := Verify(zipPath)
+	if err != nil || !res.OK() || res.Checked != 2 {
+		t.Fatalf("verify: %+v %v", res, err)
+	}
+
+	// Архив с изменённым файлом, но старым манифестом.
+	if err := os.WriteFile(filepath.Join(src, "main.db"), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, ManifestName), mustJSON(t, got), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if zipPath, err = ZipDirectory(context.Background(), src); err != nil {
+		t.Fatal(err)
+	}
+	res, err = Verify(zipPath)
+	if err != nil || res.OK() || len(res.Mismatched) != 1 || res.Mismatched[0] != "main.db" {
+		t.Fatalf("verify tampered: %+v %v", res, err)
+	}
+}
+
+func mustJSON(t *testing.T, m Manifest) []byte {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSplitJoin(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.zip")
+	if err := os.WriteFile(src, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := SHA256File(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := Split(context.Background(), src, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("parts: %v", parts)
+	}
+	found, err := FindParts(parts[0])
+	if err != nil || len(found) != 3 {
+		t.Fatalf("find parts: %v %v", found, err)
+	}
+
+	out := filepath.Join(dir, "joined.zip")
+	if err := Join(found, out, sum); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "0123456789" {
+		t.Fatalf("joined: %q", data)
+	}
+	if err := Join(found[:2], out, sum); err == nil {
+		t.Fatal("expected checksum error for missing part")
+	}
+
+	exact, err := Split(context.Background(), src, 5)
+	if err != nil || len(exact) != 2 {
+		t.Fatalf("exact split: %v %v", exact, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := filepath.Join(dir, "cancelled.zip")
+	if err := os.WriteFile(cancelled, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if parts, err := Split(ctx, cancelled, 4); !errors.Is(err, context.Canceled) || len(parts) != 0 {
+		t.Fatalf("cancelled split: %v %v", parts, err)
+	}
+	if _, err := os.Stat(PartName(cancelled, 1)); !os.IsNotExist(err) {
+		t.Fatalf("part left after cancelled split: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"tgdump/internal/config"
+	"tgdump/internal/telegram"
+)
+
+// Шаги резервного копирования для уведомлений об ошибках.
+const (
+	StepPrepare   = "подготовка"
+	StepDump      = "дамп базы"
+	StepVerify    = "проверка восстановления"
+	StepCopy      = "копирование"
+	StepArchive   = "создание архива"
+	StepState     = "сохранение состояния"
+	StepRetention = "очистка старых архивов"
+	StepSend      = "отправка в Telegram"
+	StepShutdown  = "остановка"
+)
+
+// StepError — ошибка шага резервного копирования для конкретного элемента.
+type StepError struct {
+	Step string
+	Item string // база, файл или каталог; пусто для шагов всего задания
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	if e.Item == "" {
+		return fmt.Sprintf("%s: %v", e.Step, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Step, e.Item, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+const stderrTailLines = 10
+
+// stderrTail возвращает последние строки stderr pg_dump/psql из цепочки ошибок.
+func stderrTail(err error) string {
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(cmdErr.Stderr), "\n")
+	if len(lines) > stderrTailLines {
+		lines = lines[len(lines)-stderrTailLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatFailure формирует уведомление об ошибках запуска.
+func FormatFailure(job, timestamp string, failures []*StepError) string {
+	var b strings.Builder
+	if job != "" {
+		fmt.Fprintf(&b, "Ошибка резервного копирования %s: %s\n", job, timestamp)
+	} else {
+		fmt.Fprintf(&b, "Ошибка резервного копирования: %s\n", timestamp)
+	}
+	for _, f := range failures {
+		fmt.Fprintf(&b, "\nШаг: %s\n", f.Step)
+		if f.Item != "" {
+			fmt.Fprintf(&b, "Элемент: %s\n", f.Item)
+		}
+		fmt.Fprintf(&b, "Ошибка: %v\n", f.Err)
+		if tail := stderrTail(f.Err); tail != "" {
+			fmt.Fprintf(&b, "stderr:\n%s\n", tail)
+		}
+	}
+	return b.String()
+}
+
+// notifyFailure отправляет уведомление об ошибках в alert_chat_id или chat_id,
+// в том числе после отмены запуска.
+func notifyFailure(ctx context.Context, cfg *config.Config, timestamp string, failures []*StepError) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	tg := telegram.NewClient(cfg.Telegram.APIURL, cfg.Telegram.Token)
+	if err := tg.SendMessage(ctx, cfg.Telegram.AlertChatID(), FormatFailure(cfg.JobName, timestamp, failures)); err != nil {
+		log.Printf("не удалось отправить уведомление об ошибке: %v", err)
+	}
+}
@@ -1,107 +1,475 @@
 package backup
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"tgdump/internal/config"
 
 	_ "github.com/lib/pq"
 )
 
-func parseExcludes(excludes []string) map[string][]string {
-	excludeMap := make(map[string][]string)
+// tableRules описывает исключённые и замаскированные колонки одной таблицы
+// и условие отбора строк.
+type tableRules struct {
+	Exclude map[string]struct{}
+	Masks   map[string]config.MaskRule
+	Filter  string
+}
+
+func parseExcludes(excludes []string, masks, filters map[string]string) (map[config.TableRef]*tableRules, error) {
+	rulesMap := make(map[config.TableRef]*tableRules)
+	tableFor := func(table config.TableRef) *tableRules {
+		r, ok := rulesMap[table]
+		if !ok {
+			r = &tableRules{
+				Exclude: make(map[string]struct{}),
+				Masks:   make(map[string]config.MaskRule),
+			}
+			rulesMap[table] = r
+		}
+		return r
+	}
+
 	for _, item := range excludes {
-		parts := strings.Split(item, ".")
-		if len(parts) != 2 {
+		table, column, err := config.ParseColumnRef(item)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+		tableFor(table).Exclude[column] = struct{}{}
+	}
+	for item, ruleStr := range masks {
+		table, column, err := config.ParseColumnRef(item)
+		if err != nil {
+			return nil, fmt.Errorf("mask: %w", err)
+		}
+		rule, err := config.ParseMaskRule(ruleStr)
+		if err != nil {
+			return nil, fmt.Errorf("mask %s: %w", item, err)
+		}
+		tableFor(table).Masks[column] = rule
+	}
+	for item, filter := range filters {
+		if strings.TrimSpace(filter) == "" {
 			continue
 		}
-		table, column := parts[0], parts[1]
-		excludeMap[table] = append(excludeMap[table], column)
+		table, err := config.ParseTableRef(item)
+		if err != nil {
+			return nil, fmt.Errorf("filters: %w", err)
+		}
+		tableFor(table).Filter = filter
 	}
-	return excludeMap
+	return rulesMap, nil
 }
 
-func getColumnsExcluding(db *sql.DB, table string, excludeCols []string) ([]string, error) {
-	excludeSet := make(map[string]struct{}, len(excludeCols))
-	for _, col := range excludeCols {
-		excludeSet[col] = struct{}{}
+// columnInfo — колонка таблицы и её тип для приведения замаскированного значения.
+type columnInfo struct {
+	Name     string
+	Type     string // format_type, например character varying(20)
+	Category string // pg_type.typcategory, S — строковые типы
+	NotNull  bool
+	MaxLen   int // для varchar(n) и char(n), иначе 0
+}
+
+func (c columnInfo) isString() bool {
+	return c.Category == "S"
+}
+
+// fits сообщает, помещается ли строка длиной n символов в колонку.
+func (c columnInfo) fits(n int) bool {
+	return c.MaxLen == 0 || n <= c.MaxLen
+}
+
+const (
+	hashLength      = 32                                    // md5 в hex
+	fakeEmailLength = len("user_@example.com") + hashLength // user_<md5>@example.com
+)
+
+// maskExpression возвращает SQL-выражение, заменяющее значение колонки, с
+// приведением к её типу. Правило, результат которого не поместится в колонку
+// или не восстановится в ней, отклоняется.
+func maskExpression(col columnInfo, rule config.MaskRule) (string, error) {
+	ident := quoteIdent(col.Name)
+	switch rule.Kind {
+	case config.MaskNull:
+		if col.NotNull {
+			return "", fmt.Errorf("правило null неприменимо к колонке NOT NULL")
+		}
+		return "NULL", nil
+	case config.MaskHash:
+		switch {
+		case col.Type == "uuid":
+			return fmt.Sprintf("md5(%s::text)::uuid", ident), nil
+		case !col.isString():
+			return "", fmt.Errorf("правило hash применимо только к строковым колонкам и uuid, тип колонки %s", col.Type)
+		case !col.fits(hashLength):
+			return "", fmt.Errorf("хеш длиной %d не помещается в колонку типа %s", hashLength, col.Type)
+		}
+		return fmt.Sprintf("md5(%s::text)::%s", ident, col.Type), nil
+	case config.MaskFixed:
+		if col.isString() && !col.fits(utf8.RuneCountInString(rule.Value)) {
+			return "", fmt.Errorf("значение %q не помещается в колонку типа %s", rule.Value, col.Type)
+		}
+		// Некорректное для типа значение даст ошибку при дампе, а не при восстановлении.
+		return fmt.Sprintf("%s::%s", quoteLiteral(rule.Value), col.Type), nil
+	case config.MaskFakeEmail:
+		switch {
+		case !col.isString():
+			return "", fmt.Errorf("правило fake_email применимо только к строковым колонкам, тип колонки %s", col.Type)
+		case !col.fits(fakeEmailLength):
+			return "", fmt.Errorf("адрес длиной %d не помещается в колонку типа %s", fakeEmailLength, col.Type)
+		}
+		return fmt.Sprintf("CASE WHEN %s IS NULL THEN NULL ELSE ('user_' || md5(%s::text) || '@example.com')::%s END", ident, ident, col.Type), nil
+	case config.MaskTruncate:
+		if !col.isString() {
+			return "", fmt.Errorf("правило truncate применимо только к строковым колонкам, тип колонки %s", col.Type)
+		}
+		return fmt.Sprintf("left(%s::text, %d)::%s", ident, rule.Length, col.Type), nil
+	default:
+		return ident, nil
 	}
+}
 
-	rows, err := db.Query(`
-		SELECT column_name
-		FROM information_schema.columns
-		WHERE table_name = $1
-		ORDER BY ordinal_position`, table)
+// tableSelection — колонки, попадающие в дамп, и выражения для их выборки.
+type tableSelection struct {
+	Columns []string
+	Exprs   []string
+}
+
+// getColumnsExcluding возвращает колонки таблицы без исключённых и
+// генерируемых (их значения вычисляются при восстановлении).
+func getColumnsExcluding(ctx context.Context, db queryer, table config.TableRef, rules *tableRules) (tableSelection, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod), t.typcategory, a.attnotnull,
+		       CASE WHEN a.atttypid IN ('varchar'::regtype, 'bpchar'::regtype) AND a.atttypmod > 4
+		            THEN a.atttypmod - 4 ELSE 0 END
+		FROM pg_attribute a
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		  AND a.attgenerated = ''
+		ORDER BY a.attnum`, quoteTable(table))
 	if err != nil {
-		return nil, err
+		return tableSelection{}, err
 	}
 	defer rows.Close()
 
-	var columns []string
+	var sel tableSelection
 	for rows.Next() {
-		var col string
-		if err := rows.Scan(&col); err != nil {
-			return nil, err
+		var col columnInfo
+		if err := rows.Scan(&col.Name, &col.Type, &col.Category, &col.NotNull, &col.MaxLen); err != nil {
+			return tableSelection{}, err
 		}
-		if _, excluded := excludeSet[col]; !excluded {
-			columns = append(columns, col)
+		if _, excluded := rules.Exclude[col.Name]; excluded {
+			continue
+		}
+		expr := quoteIdent(col.Name)
+		if rule, masked := rules.Masks[col.Name]; masked {
+			expr, err = maskExpression(col, rule)
+			if err != nil {
+				return tableSelection{}, fmt.Errorf("mask %s.%s: %w", table, col.Name, err)
+			}
 		}
+		sel.Columns = append(sel.Columns, col.Name)
+		sel.Exprs = append(sel.Exprs, expr)
+	}
+	if err := rows.Err(); err != nil {
+		return tableSelection{}, err
+	}
+	if len(sel.Columns) == 0 {
+		return tableSelection{}, fmt.Errorf("нет колонок для выгрузки: таблица не найдена или все колонки исключены")
 	}
-	return columns, rows.Err()
+	return sel, nil
 }
 
-func prepareTempTable(cfg config.DumpConfig, table string, columns []string) error {
-	query := fmt.Sprintf(`
-		DROP TABLE IF EXISTS %s_temp;
-		CREATE TABLE %s_temp AS SELECT %s FROM %s;`,
-		table, table, strings.Join(columns, ", "), table)
-	_, err := runPsql(cfg, query)
+// writeFilteredTableData дописывает в дамп данные таблицы только по выбранным
+// колонкам в формате, который pg_dump использует для COPY. Исходная база
+// только читается, данные берутся из снимка snapshot.
+func writeFilteredTableData(ctx context.Context, cfg config.DumpConfig, snapshot string, w io.Writer, table config.TableRef, sel tableSelection, filter string) error {
+	quoted := make([]string, len(sel.Columns))
+	for i, col := range sel.Columns {
+		quoted[i] = quoteIdent(col)
+	}
+	target := quoteTable(table)
+	colList := strings.Join(quoted, ", ")
+
+	if _, err := fmt.Fprintf(w, "\n--\n-- Data for %s (filtered by tgdump)\n--\n\nCOPY %s (%s) FROM stdin;\n", target, target, colList); err != nil {
+		return err
+	}
+	selectQuery := fmt.Sprintf("SELECT %s FROM %s", strings.Join(sel.Exprs, ", "), target)
+	if filter != "" {
+		selectQuery += " WHERE (" + filter + ")"
+	}
+	query := fmt.Sprintf("COPY (%s) TO STDOUT", selectQuery)
+	if err := runPsqlCopy(ctx, cfg, snapshot, w, query); err != nil {
+		return fmt.Errorf("выгрузка данных таблицы %s: %w", table, err)
+	}
+	_, err := io.WriteString(w, "\\.\n\n")
 	return err
 }
 
-func DumpDatabaseEx(cfg config.DumpConfig, outFile string) ([]TableRowCount, error) {
-	excludeMap := parseExcludes(cfg.Exclude)
+// sortedTables возвращает таблицы с правилами из выгружаемых схем.
+func sortedTables(cfg config.DumpConfig, rulesMap map[config.TableRef]*tableRules) []config.TableRef {
+	tables := make([]config.TableRef, 0, len(rulesMap))
+	for table := range rulesMap {
+		if cfg.SchemaSelected(table.Schema) {
+			tables = append(tables, table)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].String() < tables[j].String()
+	})
+	return tables
+}
+
+// maskedColumns перечисляет исключённые и замаскированные колонки для отчёта.
+func maskedColumns(tables []config.TableRef, rulesMap map[config.TableRef]*tableRules) []MaskedColumn {
+	var out []MaskedColumn
+	for _, table := range tables {
+		rules := rulesMap[table]
+		cols := make([]string, 0, len(rules.Exclude)+len(rules.Masks))
+		for col := range rules.Exclude {
+			cols = append(cols, col)
+		}
+		for col := range rules.Masks {
+			if _, excluded := rules.Exclude[col]; !excluded {
+				cols = append(cols, col)
+			}
+		}
+		sort.Strings(cols)
+		for _, col := range cols {
+			rule := "exclude"
+			if _, excluded := rules.Exclude[col]; !excluded {
+				rule = rules.Masks[col].String()
+			}
+			out = append(out, MaskedColumn{Column: table.String() + "." + col, Rule: rule})
+		}
+	}
+	return out
+}
+
+func openDB(cfg config.DumpConfig) (*sql.DB, error) {
+	params := []string{
+		"host=" + quoteConnValue(cfg.Host),
+		"port=" + quoteConnValue(cfg.Port),
+		"user=" + quoteConnValue(cfg.User),
+		"dbname=" + quoteConnValue(cfg.DBName),
+		"sslmode=disable",
+	}
+	if cfg.Password != "" {
+		params = append(params, "password="+quoteConnValue(cfg.Password))
+	}
+	if cfg.PassFile != "" {
+		params = append(params, "passfile="+quoteConnValue(cfg.PassFile))
+	}
+	return sql.Open("postgres", strings.Join(params, " "))
+}
+
+// quoteConnValue экранирует значение для строки подключения key=value.
+func quoteConnValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
 
-	dbinfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName)
-	db, err := sql.Open("postgres", dbinfo)
+// dumpFileName возвращает имя файла (или каталога) дампа для формата.
+func dumpFileName(cfg config.DumpConfig) string {
+	switch config.NormalizeFormat(cfg.Format) {
+	case config.FormatCustom:
+		return cfg.DBName + ".dump"
+	case config.FormatDirectory:
+		return cfg.DBName
+	case config.FormatTar:
+		return cfg.DBName + ".tar"
+	default:
+		return cfg.DBName + ".sql"
+	}
+}
+
+// filteredDataFileName возвращает имя SQL-файла с отфильтрованными данными,
+// который пишется рядом с дампом в не-plain форматах.
+func filteredDataFileName(cfg config.DumpConfig) string {
+	return cfg.DBName + ".filtered.sql"
+}
+
+// DumpResult — результат дампа одной базы.
+type DumpResult struct {
+	Paths  []string // созданные файлы и каталоги
+	Tables []TableRowCount
+	Masked []MaskedColumn
+}
+
+// DumpDatabaseEx выгружает базу в каталог dir. При ошибке частично записанные
+// файлы дампа удаляются.
+func DumpDatabaseEx(ctx context.Context, cfg config.DumpConfig, dir string) (result DumpResult, err error) {
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(filepath.Join(dir, dumpFileName(cfg)))
+			_ = os.Remove(filepath.Join(dir, filteredDataFileName(cfg)))
+		}
+	}()
+
+	rulesMap, err := parseExcludes(cfg.Exclude, cfg.Mask, cfg.Filters)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка подключения к базе: %w", err)
+		return DumpResult{}, err
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return DumpResult{}, fmt.Errorf("ошибка подключения к базе: %w", err)
 	}
 	defer db.Close()
 
-	for table, excludedCols := range excludeMap {
-		cols, err := getColumnsExcluding(db, table, excludedCols)
+	// Все запуски pg_dump, COPY и подсчёт строк читают один снимок, который
+	// живёт, пока открыта эта транзакция.
+	tx, snapshot, err := exportSnapshot(ctx, db)
+	if err != nil {
+		return DumpResult{}, err
+	}
+	defer tx.Rollback()
+
+	tables := sortedTables(cfg, rulesMap)
+	selections := make(map[config.TableRef]tableSelection, len(tables))
+	for _, table := range tables {
+		sel, err := getColumnsExcluding(ctx, tx, table, rulesMap[table])
 		if err != nil {
-			return nil, fmt.Errorf("не удалось получить колонки таблицы %s: %w", table, err)
+			return DumpResult{}, fmt.Errorf("не удалось получить колонки таблицы %s: %w", table, err)
 		}
-		if err := prepareTempTable(cfg, table, cols); err != nil {
-			return nil, err
+		selections[table] = sel
+	}
+
+	stats, err := collectDumpedTableStats(ctx, tx, cfg, rulesMap)
+	if err != nil {
+		return DumpResult{}, err
+	}
+	result = DumpResult{Tables: stats, Masked: maskedColumns(tables, rulesMap)}
+
+	outPath := filepath.Join(dir, dumpFileName(cfg))
+	if cfg.Format.IsPlain() {
+		if err := dumpPlain(ctx, cfg, snapshot, outPath, tables, rulesMap, selections); err != nil {
+			return DumpResult{}, err
 		}
+		result.Paths = []string{outPath}
+		return result, nil
 	}
-	defer dropTempTables(cfg, excludeMap)
 
-	stats, err := collectDumpedTableStats(db, excludeMap)
+	result.Paths, err = dumpArchive(ctx, cfg, snapshot, dir, outPath, tables, rulesMap, selections)
 	if err != nil {
-		return nil, err
+		return DumpResult{}, err
 	}
+	return result, nil
+}
+
+// exportSnapshot открывает транзакцию REPEATABLE READ и экспортирует её
+// снимок для pg_dump --snapshot и SET TRANSACTION SNAPSHOT. Снимок доступен,
+// пока транзакция не завершена.
+func exportSnapshot(ctx context.Context, db *sql.DB) (*sql.Tx, string, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка подключения к базе: %w", err)
+	}
+	var snapshot string
+	if err := tx.QueryRowContext(ctx, "SELECT pg_export_snapshot()").Scan(&snapshot); err != nil {
+		_ = tx.Rollback()
+		return nil, "", fmt.Errorf("не удалось экспортировать снимок: %w", err)
+	}
+	return tx, snapshot, nil
+}
 
+func pgDumpBaseArgs(cfg config.DumpConfig, snapshot string) []string {
 	args := []string{
 		"-h", cfg.Host,
 		"-p", cfg.Port,
 		"-U", cfg.User,
-		"-F", "p",
-		"-f", outFile,
+		"-F", cfg.Format.PgDumpFlag(),
+		"--snapshot=" + snapshot,
 	}
-	for table := range excludeMap {
-		args = append(args, fmt.Sprintf("--exclude-table=public.%s", table))
+	for _, schema := range cfg.Schemas {
+		args = append(args, "--schema="+quoteIdent(schema))
+	}
+	for _, schema := range cfg.ExcludeSchemas {
+		args = append(args, "--exclude-schema="+quoteIdent(schema))
+	}
+	return args
+}
+
+func dumpPlain(ctx context.Context, cfg config.DumpConfig, snapshot, outPath string, tables []config.TableRef, rulesMap map[config.TableRef]*tableRules, selections map[config.TableRef]tableSelection) error {
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла дампа: %w", err)
+	}
+	defer out.Close()
+
+	args := pgDumpBaseArgs(cfg, snapshot)
+	if len(tables) == 0 {
+		if err := runPgDump(ctx, cfg, out, append(args, cfg.DBName)...); err != nil {
+			return err
+		}
+		return out.Close()
+	}
+
+	// Схема и данные остальных таблиц, затем отфильтрованные данные,
+	// и только после них индексы и внешние ключи.
+	preArgs := append([]string{}, args...)
+	preArgs = append(preArgs, "--section=pre-data", "--section=data")
+	for _, table := range tables {
+		preArgs = append(preArgs, "--exclude-table-data="+quoteTable(table))
+	}
+	preArgs = append(preArgs, cfg.DBName)
+	if err := runPgDump(ctx, cfg, out, preArgs...); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := writeFilteredTableData(ctx, cfg, snapshot, out, table, selections[table], rulesMap[table].Filter); err != nil {
+			return err
+		}
+	}
+
+	postArgs := append(append([]string{}, args...), "--section=post-data", cfg.DBName)
+	if err := runPgDump(ctx, cfg, out, postArgs...); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// dumpArchive создаёт дамп в формате custom, directory или tar. Данные таблиц
+// с исключёнными колонками в такой дамп не попадают и пишутся отдельным
+// SQL-файлом, который применяется между секциями data и post-data.
+func dumpArchive(ctx context.Context, cfg config.DumpConfig, snapshot, dir, outPath string, tables []config.TableRef, rulesMap map[config.TableRef]*tableRules, selections map[config.TableRef]tableSelection) ([]string, error) {
+	args := append(pgDumpBaseArgs(cfg, snapshot), "-f", outPath)
+	if config.NormalizeFormat(cfg.Format) == config.FormatDirectory && cfg.Jobs > 1 {
+		args = append(args, "--jobs", strconv.Itoa(cfg.Jobs))
+	}
+	for _, table := range tables {
+		args = append(args, "--exclude-table-data="+quoteTable(table))
 	}
 	args = append(args, cfg.DBName)
+	if err := runPgDump(ctx, cfg, nil, args...); err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return []string{outPath}, nil
+	}
 
-	if err := runPgDump(cfg, args...); err != nil {
+	filteredPath := filepath.Join(dir, filteredDataFileName(cfg))
+	out, err := os.Create(filteredPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания файла данных: %w", err)
+	}
+	defer out.Close()
+	for _, table := range tables {
+		if err := writeFilteredTableData(ctx, cfg, snapshot, out, table, selections[table], rulesMap[table].Filter); err != nil {
+			return nil, err
+		}
+	}
+	if err := out.Close(); err != nil {
 		return nil, err
 	}
-	return stats, nil
+	return []string{outPath, filteredPath}, nil
 }
@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+	"tgdump/internal/restore"
+)
+
+func TestMaskExpression(t *testing.T) {
+	text := columnInfo{Name: "c", Type: "text", Category: "S"}
+	short := columnInfo{Name: "c", Type: "character varying(10)", Category: "S", MaxLen: 10}
+	integer := columnInfo{Name: "c", Type: "integer", Category: "N", NotNull: true}
+	uuid := columnInfo{Name: "c", Type: "uuid", Category: "U"}
+
+	cases := []struct {
+		col  columnInfo
+		rule string
+		want string // пусто — правило должно быть отклонено
+	}{
+		{text, "hash", `md5("c"::text)::text`},
+		{uuid, "hash", `md5("c"::text)::uuid`},
+		{short, "hash", ""},
+		{integer, "hash", ""},
+		{integer, "null", ""},
+		{integer, "fixed:0", `'0'::integer`},
+		{short, "fixed:0123456789", `'0123456789'::character varying(10)`},
+		{short, "fixed:01234567890", ""},
+		{short, "fake_email", ""},
+		{text, "truncate:3", `left("c"::text, 3)::text`},
+		{integer, "truncate:3", ""},
+	}
+	for _, tc := range cases {
+		rule, err := config.ParseMaskRule(tc.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := maskExpression(tc.col, rule)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s для %s: ожидалась ошибка, получено %s", tc.rule, tc.col.Type, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s для %s: %q, %v; ожидается %q", tc.rule, tc.col.Type, got, err, tc.want)
+		}
+	}
+}
+
+// testServer возвращает сервер PostgreSQL из PGHOST, PGPORT, PGUSER и
+// PGPASSWORD или пропускает тест.
+func testServer(t *testing.T) config.DumpConfig {
+	t.Helper()
+	if os.Getenv("PGHOST") == "" {
+		t.Skip("PGHOST не задан")
+	}
+	for _, name := range []string{"pg_dump", "psql"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s не найден", name)
+		}
+	}
+	cfg := config.DumpConfig{
+		Host:     os.Getenv("PGHOST"),
+		Port:     os.Getenv("PGPORT"),
+		User:     os.Getenv("PGUSER"),
+		Password: os.Getenv("PGPASSWORD"),
+	}
+	if cfg.Port == "" {
+		cfg.Port = "5432"
+	}
+	if cfg.User == "" {
+		cfg.User = "postgres"
+	}
+	return cfg
+}
+
+func TestDumpRestoreMaskedColumns(t *testing.T) {
+	ctx := context.Background()
+	src := testServer(t)
+	src.DBName = "tgdump_test_mask_src"
+	dst := src
+	dst.DBName = "tgdump_test_mask_dst"
+	t.Cleanup(func() {
+		_ = restore.DropDatabase(context.Background(), src)
+		_ = restore.DropDatabase(context.Background(), dst)
+	})
+
+	createDatabase(t, src, `
+CREATE TABLE people (
+	id integer PRIMARY KEY,
+	ref uuid NOT NULL,
+	born date NOT NULL,
+	extra jsonb NOT NULL,
+	code varchar(8) NOT NULL,
+	email varchar(64),
+	note text NOT NULL
+);
+INSERT INTO people VALUES
+	(1, gen_random_uuid(), '1990-05-01', '{"a": 1}', 'ABCDEFGH', 'a@b.c', 'secret'),
+	(2, gen_random_uuid(), '1985-01-01', '[]', 'XYZ', NULL, 'other');
+`)
+
+	src.Mask = map[string]string{
+		"people.ref":   "hash",
+		"people.born":  "fixed:2000-01-01",
+		"people.extra": "fixed:{}",
+		"people.code":  "truncate:2",
+		"people.email": "fake_email",
+		"people.note":  "hash",
+	}
+	dir := t.TempDir()
+	result, err := DumpDatabaseEx(ctx, src, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := manifestDatabase(src, result.Paths, result.Tables)
+	if err := restore.RestoreDump(ctx, dst, entry, dir, restore.DumpOptions{Drop: true}); err != nil {
+		t.Fatalf("восстановление замаскированного дампа: %v", err)
+	}
+
+	db, err := openDB(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var born, extra, code string
+	if err := db.QueryRowContext(ctx, `SELECT born::text, extra::text, code FROM people WHERE id = 1`).Scan(&born, &extra, &code); err != nil {
+		t.Fatal(err)
+	}
+	if born != "2000-01-01" || extra != "{}" || code != "AB" {
+		t.Fatalf("born=%s extra=%s code=%s", born, extra, code)
+	}
+
+	src.Mask = map[string]string{"people.note": "null"}
+	if _, err := DumpDatabaseEx(ctx, src, t.TempDir()); err == nil || !strings.Contains(err.Error(), "NOT NULL") {
+		t.Fatalf("null для NOT NULL: %v", err)
+	}
+	src.Mask = map[string]string{"people.code": "hash"}
+	if _, err := DumpDatabaseEx(ctx, src, t.TempDir()); err == nil {
+		t.Fatal("hash для varchar(8): ожидалась ошибка")
+	}
+}
+
+// createDatabase пересоздаёт базу target и выполняет в ней script.
+func createDatabase(t *testing.T, target config.DumpConfig, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "init.sql"), []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entry := archive.ManifestDatabase{Name: target.DBName, Format: string(config.FormatPlain), Path: "init.sql"}
+	if err := restore.RestoreDump(context.Background(), target, entry, dir, restore.DumpOptions{Drop: true}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,13 +1,17 @@
 package backup
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
 )
 
 // Копирование файла с сохранением прав доступа
-func CopyFile(src, dst string) error {
+func CopyFile(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -29,7 +33,8 @@ func CopyFile(src, dst string) error {
 	return err
 }
 
-func CopyDir(from, to string) error {
+// CopyDir копирует каталог; отмена ctx проверяется перед каждым файлом.
+func CopyDir(ctx context.Context, from, to string) error {
 	from, err := filepath.Abs(from)
 	if err != nil {
 		return err
@@ -61,6 +66,18 @@ func CopyDir(from, to string) error {
 		if info.IsDir() {
 			return os.MkdirAll(destPath, info.Mode())
 		}
-		return CopyFile(path, destPath)
+		return CopyFile(ctx, path, destPath)
 	})
 }
+
+// CopyPath копирует файл или каталог в зависимости от типа src.
+func CopyPath(ctx context.Context, src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return CopyDir(ctx, src, dst)
+	}
+	return CopyFile(ctx, src, dst)
+}
@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"tgdump/internal/runstate"
+)
+
+type fileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// dirState — манифест каталога после последнего успешного запуска. Base —
+// метка времени архива с последней полной копией.
+type dirState struct {
+	Path          string               `json:"path"`
+	Base          string               `json:"base"`
+	RunsSinceFull int                  `json:"runs_since_full"`
+	Files         map[string]fileState `json:"files"`
+}
+
+// pendingState сохраняется только после успешного создания архива, иначе
+// следующий инкремент пропустил бы изменения.
+type pendingState struct {
+	path  string
+	state dirState
+}
+
+func (p pendingState) save() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог состояния: %w", err)
+	}
+	data, err := json.Marshal(p.state)
+	if err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи состояния %s: %w", p.path, err)
+	}
+	return os.Rename(tmp, p.path)
+}
+
+var unsafeStateChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func incrementalStatePath(dumpDir, job, assetPath string) string {
+	name := unsafeStateChars.ReplaceAllString(filepath.ToSlash(filepath.Clean(assetPath)), "_")
+	if job != "" {
+		name = job + "__" + name
+	}
+	return filepath.Join(runstate.Dir(dumpDir), "incremental", name+".json")
+}
+
+func loadDirState(path string) (dirState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return dirState{}, nil
+	}
+	if err != nil {
+		return dirState{}, fmt.Errorf("ошибка чтения состояния %s: %w", path, err)
+	}
+	var st dirState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return dirState{}, fmt.Errorf("ошибка разбора состояния %s: %w", path, err)
+	}
+	return st, nil
+}
+
+type incrementalResult struct {
+	State   dirState
+	Full    bool
+	Changed int
+	Deleted []string
+	Report  DirectoryReport
+}
+
+// copyIncremental копирует из src в dst новые и изменённые с прошлого запуска
+// файлы (или все при полной копии) и строит новый манифест каталога. Хеш
+// пересчитывается только у файлов с изменившимися размером или mtime.
+func copyIncremental(ctx context.Context, src, dst, displayName string, prev dirState, fullEvery int) (incrementalResult, error) {
+	full := len(prev.Files) == 0 || prev.RunsSinceFull+1 >= fullEvery
+	res := incrementalResult{
+		State: dirState{Path: src, Files: make(map[string]fileState)},
+		Full:  full,
+	}
+	if !full {
+		res.State.RunsSinceFull = prev.RunsSinceFull + 1
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return res, err
+	}
+
+	var sizeBytes int64
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		res.Report.FileCount++
+		sizeBytes += info.Size()
+
+		old, known := prev.Files[rel]
+		cur := fileState{Size: info.Size(), ModTime: info.ModTime()}
+		unchanged := known && old.Size == cur.Size && old.ModTime.Equal(cur.ModTime)
+		if unchanged && !full {
+			cur.SHA256 = old.SHA256
+			res.State.Files[rel] = cur
+			return nil
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		sum, err := copyFileHashing(path, target)
+		if err != nil {
+			return fmt.Errorf("копирование %s: %w", path, err)
+		}
+		cur.SHA256 = sum
+		res.State.Files[rel] = cur
+		if !full && known && old.SHA256 == sum {
+			// Изменился только mtime — в инкремент файл не нужен.
+			return os.Remove(target)
+		}
+		res.Changed++
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("не удалось просканировать каталог %s: %w", src, err)
+	}
+
+	if !full {
+		for rel := range prev.Files {
+			if _, ok := res.State.Files[rel]; !ok {
+				res.Deleted = append(res.Deleted, rel)
+			}
+		}
+		sort.Strings(res.Deleted)
+	}
+
+	res.Report.Name = displayName
+	res.Report.SizeMB = float64(sizeBytes) / bytesPerMB
+	return res, nil
+}
+
+func copyFileHashing(src, dst string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), out.Close()
+}
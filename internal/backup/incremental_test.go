@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyIncremental(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	writeTestFile(t, filepath.Join(src, "a.txt"), "a")
+	writeTestFile(t, filepath.Join(src, "sub", "b.txt"), "b")
+	writeTestFile(t, filepath.Join(src, "c.txt"), "c")
+
+	first, err := copyIncremental(context.Background(), src, filepath.Join(root, "run1"), "src", dirState{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Full || first.Changed != 3 || first.Report.FileCount != 3 {
+		t.Fatalf("first run: %+v", first)
+	}
+
+	writeTestFile(t, filepath.Join(src, "a.txt"), "a changed")
+	if err := os.Remove(filepath.Join(src, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// Только новый mtime без изменения содержимого не попадает в инкремент.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "sub", "b.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := copyIncremental(context.Background(), src, filepath.Join(root, "run2"), "src", first.State, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Full || second.Changed != 1 || len(second.Deleted) != 1 || second.Deleted[0] != "c.txt" {
+		t.Fatalf("second run: full=%v changed=%d deleted=%v", second.Full, second.Changed, second.Deleted)
+	}
+	if _, err := os.Stat(filepath.Join(root, "run2", "a.txt")); err != nil {
+		t.Fatalf("changed file not copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "run2", "sub", "b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("touched file should not be copied: %v", err)
+	}
+
+	third, err := copyIncremental(context.Background(), src, filepath.Join(root, "run3"), "src", second.State, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Full || third.Changed != 0 {
+		t.Fatalf("third run: full=%v changed=%d", third.Full, third.Changed)
+	}
+	fourth, err := copyIncremental(context.Background(), src, filepath.Join(root, "run4"), "src", third.State, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fourth.Full || fourth.Changed != 2 {
+		t.Fatalf("fourth run should be full: full=%v changed=%d", fourth.Full, fourth.Changed)
+	}
+}
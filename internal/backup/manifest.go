@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+	"tgdump/internal/version"
+)
+
+// manifestDatabaseConfig — запись базы в манифесте без пароля.
+type manifestDatabaseConfig struct {
+	Host           string            `json:"host"`
+	Port           string            `json:"port"`
+	User           string            `json:"user"`
+	Name           string            `json:"name"`
+	Format         config.DumpFormat `json:"format"`
+	Delivery       config.Delivery   `json:"delivery"`
+	Exclude        []string          `json:"exclude,omitempty"`
+	Mask           map[string]string `json:"mask,omitempty"`
+	Filters        map[string]string `json:"filters,omitempty"`
+	Schemas        []string          `json:"schemas,omitempty"`
+	ExcludeSchemas []string          `json:"exclude_schemas,omitempty"`
+}
+
+type manifestAssetConfig struct {
+	Path        string          `json:"path"`
+	Delivery    config.Delivery `json:"delivery"`
+	Incremental bool            `json:"incremental,omitempty"`
+	FullEvery   int             `json:"full_every,omitempty"`
+}
+
+type manifestConfig struct {
+	Databases   []manifestDatabaseConfig `json:"databases"`
+	Files       []manifestAssetConfig    `json:"files"`
+	Directories []manifestAssetConfig    `json:"directories"`
+	FilesDir    string                   `json:"files_dir"`
+}
+
+func newManifest(job, timestamp string) archive.Manifest {
+	return archive.Manifest{Version: version.String(), Job: job, Timestamp: timestamp}
+}
+
+// sanitizedConfig возвращает записи конфигурации для манифеста: без паролей,
+// токена Telegram и ключей шифрования.
+func sanitizedConfig(cfg *config.Config) json.RawMessage {
+	mc := manifestConfig{FilesDir: cfg.FilesDir}
+	for _, db := range cfg.Databases {
+		mc.Databases = append(mc.Databases, manifestDatabaseConfig{
+			Host:           db.Host,
+			Port:           db.Port,
+			User:           db.User,
+			Name:           db.DBName,
+			Format:         db.Format,
+			Delivery:       db.Delivery,
+			Exclude:        db.Exclude,
+			Mask:           db.Mask,
+			Filters:        db.Filters,
+			Schemas:        db.Schemas,
+			ExcludeSchemas: db.ExcludeSchemas,
+		})
+	}
+	for _, f := range cfg.Files {
+		mc.Files = append(mc.Files, manifestAssetConfig{Path: f.Path, Delivery: f.Delivery})
+	}
+	for _, d := range cfg.Directories {
+		mc.Directories = append(mc.Directories, manifestAssetConfig{
+			Path:        d.Path,
+			Delivery:    d.Delivery,
+			Incremental: d.Incremental,
+			FullEvery:   d.FullEvery,
+		})
+	}
+	data, err := json.Marshal(mc)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// sentItems возвращает копию cfg только с базами, файлами и каталогами,
+// которые есть в манифесте m.
+func sentItems(cfg *config.Config, m archive.Manifest) *config.Config {
+	sent := *cfg
+	sent.Databases, sent.Files, sent.Directories = nil, nil, nil
+	for _, db := range cfg.Databases {
+		if slices.ContainsFunc(m.Databases, func(e archive.ManifestDatabase) bool { return e.Name == db.DBName }) {
+			sent.Databases = append(sent.Databases, db)
+		}
+	}
+	inManifest := func(assets []archive.ManifestAsset, path string) bool {
+		return slices.ContainsFunc(assets, func(a archive.ManifestAsset) bool { return a.Path == filepath.ToSlash(path) })
+	}
+	for _, f := range cfg.Files {
+		if inManifest(m.Files, f.Path) {
+			sent.Files = append(sent.Files, f)
+		}
+	}
+	for _, d := range cfg.Directories {
+		if inManifest(m.Directories, d.Path) {
+			sent.Directories = append(sent.Directories, d)
+		}
+	}
+	return &sent
+}
+
+// manifestDatabase описывает дамп базы; пути в архиве совпадают с именами в archiveDir.
+func manifestDatabase(db config.DumpConfig, paths []string, tables []TableRowCount) archive.ManifestDatabase {
+	entry := archive.ManifestDatabase{
+		Name:   db.DBName,
+		Format: string(db.Format),
+		Path:   filepath.Base(paths[0]),
+	}
+	if len(paths) > 1 {
+		entry.Filtered = filepath.Base(paths[1])
+	}
+	for _, t := range tables {
+		entry.Tables = append(entry.Tables, archive.ManifestTable{Schema: t.Schema, Name: t.Name, Rows: t.Rows})
+	}
+	return entry
+}
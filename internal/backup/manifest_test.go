@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+)
+
+func TestSentItemsConfig(t *testing.T) {
+	cfg := &config.Config{
+		Databases: []config.DumpConfig{
+			{Host: "public-db", DBName: "shop", Delivery: config.DeliverySend},
+			{Host: "private-db", DBName: "hr", Delivery: config.DeliverySave, Mask: map[string]string{"staff.salary": "null"}},
+		},
+		Files: config.AssetList{{Path: "./a.txt", Delivery: config.DeliverySend}, {Path: "./secret.txt", Delivery: config.DeliverySave}},
+	}
+	m := archive.Manifest{
+		Databases: []archive.ManifestDatabase{{Name: "shop"}},
+		Files:     []archive.ManifestAsset{{Path: "./a.txt"}},
+	}
+	data := string(sanitizedConfig(sentItems(cfg, m)))
+	for _, hidden := range []string{"private-db", "hr", "staff.salary", "secret.txt"} {
+		if strings.Contains(data, hidden) {
+			t.Errorf("в отправляемом манифесте %q: %s", hidden, data)
+		}
+	}
+	if !strings.Contains(data, "public-db") || !strings.Contains(data, "a.txt") {
+		t.Errorf("отправленные элементы потеряны: %s", data)
+	}
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"tgdump/internal/config"
+)
+
+type dumpOutcome struct {
+	Result DumpResult
+	Size   int64
+	Err    error
+}
+
+// dumpDatabases выгружает базы в dir параллельно с ограничениями из limits.
+// Результаты возвращаются в порядке dbs; ошибка одной базы не прерывает остальные.
+// После отмены ctx ещё не начатые дампы не запускаются.
+func dumpDatabases(ctx context.Context, dbs []config.DumpConfig, dir string, limits config.ParallelConfig) []dumpOutcome {
+	return runParallel(ctx, dbs, limits, func(db config.DumpConfig) dumpOutcome {
+		result, err := DumpDatabaseEx(ctx, db, dir)
+		if err != nil {
+			return dumpOutcome{Err: err}
+		}
+		size, err := pathsSize(result.Paths)
+		return dumpOutcome{Result: result, Size: size, Err: err}
+	})
+}
+
+func runParallel(ctx context.Context, dbs []config.DumpConfig, limits config.ParallelConfig, dump func(config.DumpConfig) dumpOutcome) []dumpOutcome {
+	outcomes := make([]dumpOutcome, len(dbs))
+	global := make(chan struct{}, max(limits.Dumps, 1))
+	hosts := make(map[string]chan struct{})
+	if limits.PerHost > 0 {
+		for _, db := range dbs {
+			key := net.JoinHostPort(db.Host, db.Port)
+			if _, ok := hosts[key]; !ok {
+				hosts[key] = make(chan struct{}, limits.PerHost)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, db := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Сначала слот сервера, потом общий: ожидающий свой сервер дамп
+			// не занимает общий слот.
+			if host := hosts[net.JoinHostPort(db.Host, db.Port)]; host != nil {
+				if !acquire(ctx, host) {
+					outcomes[i] = dumpOutcome{Err: ctx.Err()}
+					return
+				}
+				defer func() { <-host }()
+			}
+			if !acquire(ctx, global) {
+				outcomes[i] = dumpOutcome{Err: ctx.Err()}
+				return
+			}
+			defer func() { <-global }()
+
+			defer func() {
+				if r := recover(); r != nil {
+					outcomes[i] = dumpOutcome{Err: fmt.Errorf("аварийное завершение дампа: %v", r)}
+				}
+			}()
+			outcomes[i] = dump(db)
+		}()
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// acquire занимает слот sem; false, если ctx отменён раньше.
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tgdump/internal/config"
+)
+
+func TestRunParallelLimits(t *testing.T) {
+	var dbs []config.DumpConfig
+	for _, host := range []string{"a", "a", "a", "b", "b", "c"} {
+		dbs = append(dbs, config.DumpConfig{Host: host, Port: "5432", DBName: host + string(rune('0'+len(dbs)))})
+	}
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	var total, maxTotal int
+	maxHost := map[string]int{}
+	outcomes := runParallel(context.Background(), dbs, config.ParallelConfig{Dumps: 3, PerHost: 1}, func(db config.DumpConfig) dumpOutcome {
+		mu.Lock()
+		running[db.Host]++
+		total++
+		maxHost[db.Host] = max(maxHost[db.Host], running[db.Host])
+		maxTotal = max(maxTotal, total)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[db.Host]--
+		total--
+		mu.Unlock()
+		if db.DBName == "b3" {
+			panic("boom")
+		}
+		if db.DBName == "c5" {
+			return dumpOutcome{Err: errors.New("нет соединения")}
+		}
+		return dumpOutcome{Size: int64(len(db.DBName))}
+	})
+
+	if maxTotal > 3 {
+		t.Errorf("max concurrent dumps = %d, want <= 3", maxTotal)
+	}
+	for host, n := range maxHost {
+		if n > 1 {
+			t.Errorf("host %s: %d concurrent dumps, want 1", host, n)
+		}
+	}
+	for i, o := range outcomes {
+		switch dbs[i].DBName {
+		case "b3", "c5":
+			if o.Err == nil {
+				t.Errorf("%s: expected error", dbs[i].DBName)
+			}
+		default:
+			if o.Err != nil || o.Size != 2 {
+				t.Errorf("%s: outcome %+v", dbs[i].DBName, o)
+			}
+		}
+	}
+}
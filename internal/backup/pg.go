@@ -2,53 +2,84 @@ package backup
 
 import (
 	"bytes"
-	"fmt"
+	"context"
+	"io"
 	"os"
 	"os/exec"
+	"syscall"
+	"time"
 
 	"tgdump/internal/config"
+	"tgdump/internal/restore"
 )
 
-func pgEnv(password string) []string {
-	return append(os.Environ(), "PGPASSWORD="+password)
+// CommandError — ошибка внешней команды (pg_dump, psql, pg_restore) с её
+// stderr; общий тип для дампа и восстановления при проверке.
+type CommandError = restore.CommandError
+
+// pgEnv возвращает окружение для pg_dump и psql. Без пароля в конфигурации
+// libpq берёт его из PGPASSFILE или ~/.pgpass.
+func pgEnv(cfg config.DumpConfig) []string {
+	env := os.Environ()
+	if cfg.Password != "" {
+		env = append(env, "PGPASSWORD="+cfg.Password)
+	}
+	if cfg.PassFile != "" {
+		env = append(env, "PGPASSFILE="+cfg.PassFile)
+	}
+	return env
 }
 
-func runPsql(cfg config.DumpConfig, query string) (string, error) {
-	cmd := exec.Command("psql",
+// runPsqlCopy выполняет COPY ... TO STDOUT в снимке snapshot и пишет данные в w.
+func runPsqlCopy(ctx context.Context, cfg config.DumpConfig, snapshot string, w io.Writer, query string) error {
+	cmd := exec.CommandContext(ctx, "psql",
+		"-X", "-q",
+		"-v", "ON_ERROR_STOP=1",
 		"-h", cfg.Host,
 		"-p", cfg.Port,
 		"-U", cfg.User,
 		"-d", cfg.DBName,
+		"-c", "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY",
+		"-c", "SET TRANSACTION SNAPSHOT "+quoteLiteral(snapshot),
 		"-c", query,
+		"-c", "COMMIT",
 	)
-	cmd.Env = pgEnv(cfg.Password)
+	cmd.Env = pgEnv(cfg)
+	stopOnCancel(cmd)
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return out.String(), fmt.Errorf("ошибка psql: %w, вывод: %s", err, out.String())
+		return &CommandError{Name: "psql", Err: err, Stderr: stderr.String()}
 	}
-	return out.String(), nil
+	return nil
 }
 
-func runPgDump(cfg config.DumpConfig, args ...string) error {
-	cmd := exec.Command("pg_dump", args...)
-	cmd.Env = pgEnv(cfg.Password)
+// runPgDump запускает pg_dump; если w не nil, stdout пишется в него.
+func runPgDump(ctx context.Context, cfg config.DumpConfig, w io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "pg_dump", args...)
+	cmd.Env = pgEnv(cfg)
+	stopOnCancel(cmd)
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("ошибка выполнения pg_dump: %w, output: %s", err, string(output))
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return &CommandError{Name: "pg_dump", Err: err, Stderr: stderr.String()}
 	}
 	return nil
 }
 
-func dropTempTables(cfg config.DumpConfig, tables map[string][]string) {
-	for table := range tables {
-		query := fmt.Sprintf("DROP TABLE IF EXISTS %s_temp;", table)
-		if _, err := runPsql(cfg, query); err != nil {
-			fmt.Printf("ошибка удаления временной таблицы %s: %v\n", table, err)
-		}
-	}
+// childStopTimeout — сколько ждать завершения дочернего процесса после
+// SIGTERM при отмене, прежде чем убить его.
+const childStopTimeout = 10 * time.Second
+
+// stopOnCancel настраивает cmd так, чтобы при отмене контекста процесс
+// получил SIGTERM и успел закрыть соединение с сервером.
+func stopOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = childStopTimeout
 }
@@ -1,21 +1,58 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 
 	"tgdump/internal/config"
 )
 
 type TableRowCount struct {
-	Name string
-	Rows int64
+	Schema   string
+	Name     string
+	Rows     int64
+	Filtered bool // в дамп попали только строки, прошедшие filters
+}
+
+type SchemaRowCount struct {
+	Name   string
+	Tables int
+	Rows   int64
+}
+
+type MaskedColumn struct {
+	Column string
+	Rule   string
 }
 
 type DatabaseReport struct {
 	Name     string
 	Delivery config.Delivery
+	Format   config.DumpFormat
+	SizeMB   float64
 	Tables   []TableRowCount
+	Masked   []MaskedColumn
+	Verify   *VerifyResult // nil, если проверка восстановления выключена
+	Error    string        // пусто, если база сохранена
+}
+
+// SchemaTotals группирует количество строк по схемам в порядке их появления.
+func (d DatabaseReport) SchemaTotals() []SchemaRowCount {
+	var totals []SchemaRowCount
+	index := make(map[string]int)
+	for _, t := range d.Tables {
+		i, ok := index[t.Schema]
+		if !ok {
+			i = len(totals)
+			index[t.Schema] = i
+			totals = append(totals, SchemaRowCount{Name: t.Schema})
+		}
+		totals[i].Tables++
+		totals[i].Rows += t.Rows
+	}
+	return totals
 }
 
 type DirectoryReport struct {
@@ -23,40 +60,202 @@ type DirectoryReport struct {
 	Delivery  config.Delivery
 	FileCount int
 	SizeMB    float64
+
+	Incremental bool // в архиве только изменения с прошлого запуска
+	Changed     int
+	Deleted     int
+
+	Error string // пусто, если каталог сохранён
 }
 
 type FileReport struct {
 	Name     string
 	Delivery config.Delivery
+	Error    string // пусто, если файл сохранён
 }
 
+// Status — итог запуска.
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusPartial Status = "partial"
+	StatusFailed  Status = "failed"
+)
+
 type Report struct {
+	Job         string
 	Timestamp   string
 	Databases   []DatabaseReport
 	Directories []DirectoryReport
 	Files       []FileReport
+	Pruned      []string // архивы, удалённые политикой хранения
+	Error       string   // ошибка создания локального архива
+	StepErrors  []*StepError
+}
+
+// fail записывает ошибку шага в отчёт и лог.
+func (r *Report) fail(step, item string, err error) *StepError {
+	se := &StepError{Step: step, Item: item, Err: err}
+	r.StepErrors = append(r.StepErrors, se)
+	if tail := stderrTail(err); tail != "" {
+		log.Printf("%v\nstderr:\n%s", se, tail)
+	} else {
+		log.Printf("%v", se)
+	}
+	return se
+}
+
+// Failures возвращает количество элементов с ошибкой и общее количество элементов.
+// База, не прошедшая проверку восстановления, считается элементом с ошибкой:
+// дамп сохранён, но восстановить его, возможно, не получится.
+func (r Report) Failures() (failed, total int) {
+	for _, db := range r.Databases {
+		if db.Error != "" || (db.Verify != nil && db.Verify.Status != VerifyOK) {
+			failed++
+		}
+	}
+	for _, d := range r.Directories {
+		if d.Error != "" {
+			failed++
+		}
+	}
+	for _, f := range r.Files {
+		if f.Error != "" {
+			failed++
+		}
+	}
+	return failed, len(r.Databases) + len(r.Directories) + len(r.Files)
+}
+
+// Status возвращает итог: все элементы сохранены, часть или ничего.
+func (r Report) Status() Status {
+	failed, total := r.Failures()
+	switch {
+	case r.Error != "" || (failed > 0 && failed == total):
+		return StatusFailed
+	case failed > 0:
+		return StatusPartial
+	default:
+		return StatusOK
+	}
+}
+
+// Err возвращает ошибку, если запуск завершился не полностью успешно.
+func (r Report) Err() error {
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	failed, total := r.Failures()
+	if failed > 0 {
+		return fmt.Errorf("резервное копирование завершено с ошибками: %d из %d элементов", failed, total)
+	}
+	return nil
+}
+
+func (s Status) Label() string {
+	switch s {
+	case StatusOK:
+		return "успешно"
+	case StatusPartial:
+		return "частично"
+	default:
+		return "ошибка"
+	}
 }
 
 func (r Report) Format() string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "Резервная копия: %s\n", r.Timestamp)
+	if r.Job != "" {
+		fmt.Fprintf(&b, "Резервная копия %s: %s\n", r.Job, r.Timestamp)
+	} else {
+		fmt.Fprintf(&b, "Резервная копия: %s\n", r.Timestamp)
+	}
+	if failed, total := r.Failures(); failed > 0 {
+		fmt.Fprintf(&b, "Статус: %s (ошибок: %d из %d)\n", r.Status().Label(), failed, total)
+	} else {
+		fmt.Fprintf(&b, "Статус: %s\n", r.Status().Label())
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "Ошибка: %s\n", r.Error)
+	}
 	for _, db := range r.Databases {
-		fmt.Fprintf(&b, "\nБаза %s [%s]:\n", db.Name, db.Delivery.Label())
-		for _, t := range db.Tables {
-			fmt.Fprintf(&b, "  %s: %d\n", t.Name, t.Rows)
+		if db.Error != "" {
+			fmt.Fprintf(&b, "\nБаза %s [%s]: ошибка: %s\n", db.Name, db.Delivery.Label(), db.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "\nБаза %s (%s, %.2f МБ) [%s]:\n", db.Name, db.Format, db.SizeMB, db.Delivery.Label())
+		for _, schema := range db.SchemaTotals() {
+			fmt.Fprintf(&b, "  Схема %s: %d таблиц, %d строк\n", schema.Name, schema.Tables, schema.Rows)
+			for _, t := range db.Tables {
+				if t.Schema != schema.Name {
+					continue
+				}
+				if t.Filtered {
+					fmt.Fprintf(&b, "    %s: %d (с фильтром)\n", t.Name, t.Rows)
+				} else {
+					fmt.Fprintf(&b, "    %s: %d\n", t.Name, t.Rows)
+				}
+			}
+		}
+		if len(db.Masked) > 0 {
+			b.WriteString("  Скрытые колонки:\n")
+			for _, m := range db.Masked {
+				fmt.Fprintf(&b, "    %s: %s\n", m.Column, m.Rule)
+			}
+		}
+		if db.Verify != nil {
+			writeVerifyResult(&b, *db.Verify)
 		}
 	}
 	if len(r.Files) > 0 {
 		b.WriteString("\nФайлы:\n")
 		for _, f := range r.Files {
 			fmt.Fprintf(&b, "  %s [%s]\n", f.Name, f.Delivery.Label())
+			if f.Error != "" {
+				fmt.Fprintf(&b, "    ошибка: %s\n", f.Error)
+			}
 		}
 	}
 	if len(r.Directories) > 0 {
 		b.WriteString("\nКаталоги:\n")
 		for _, d := range r.Directories {
+			if d.Error != "" && d.FileCount == 0 {
+				fmt.Fprintf(&b, "  %s [%s]\n    ошибка: %s\n", d.Name, d.Delivery.Label(), d.Error)
+				continue
+			}
 			fmt.Fprintf(&b, "  %s: %d файлов, %.2f МБ [%s]\n", d.Name, d.FileCount, d.SizeMB, d.Delivery.Label())
+			if d.Incremental {
+				fmt.Fprintf(&b, "    инкремент: изменено %d, удалено %d\n", d.Changed, d.Deleted)
+			}
+			if d.Error != "" {
+				fmt.Fprintf(&b, "    ошибка: %s\n", d.Error)
+			}
+		}
+	}
+	if len(r.Pruned) > 0 {
+		b.WriteString("\nУдалены старые архивы:\n")
+		for _, name := range r.Pruned {
+			fmt.Fprintf(&b, "  %s\n", name)
 		}
 	}
 	return b.String()
 }
+
+func writeVerifyResult(b *strings.Builder, v VerifyResult) {
+	switch v.Status {
+	case VerifyOK:
+		b.WriteString("  Проверка восстановления: успешно\n")
+	case VerifyMismatch:
+		b.WriteString("  Проверка восстановления: расхождения в количестве строк\n")
+		for _, m := range v.Mismatches {
+			if m.Actual < 0 {
+				fmt.Fprintf(b, "    %s: ожидалось %d, таблица отсутствует\n", m.Table, m.Expected)
+			} else {
+				fmt.Fprintf(b, "    %s: ожидалось %d, получено %d\n", m.Table, m.Expected, m.Actual)
+			}
+		}
+	default:
+		fmt.Fprintf(b, "  Проверка восстановления: ошибка: %s\n", v.Error)
+	}
+}
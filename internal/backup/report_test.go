@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReportStatus(t *testing.T) {
+	r := Report{
+		Databases: []DatabaseReport{{Name: "app"}, {Name: "crm", Error: "ошибка подключения к базе"}},
+		Files:     []FileReport{{Name: "settings.json"}},
+	}
+	if got := r.Status(); got != StatusPartial {
+		t.Fatalf("status = %s, want partial", got)
+	}
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "1 из 3") {
+		t.Fatalf("err = %v", err)
+	}
+	text := r.Format()
+	if !strings.Contains(text, "Статус: частично (ошибок: 1 из 3)") || !strings.Contains(text, "База crm [сохранение и отправка]: ошибка: ошибка подключения к базе") {
+		t.Fatalf("unexpected report:\n%s", text)
+	}
+
+	r.Databases[0].Error = "x"
+	r.Files[0].Error = "x"
+	if got := r.Status(); got != StatusFailed {
+		t.Fatalf("status = %s, want failed", got)
+	}
+	if got := (Report{}).Status(); got != StatusOK {
+		t.Fatalf("empty status = %s", got)
+	}
+
+	verified := Report{Databases: []DatabaseReport{
+		{Name: "app", Verify: &VerifyResult{Status: VerifyOK}},
+		{Name: "crm", Verify: &VerifyResult{Status: VerifyMismatch}},
+	}}
+	if got := verified.Status(); got != StatusPartial {
+		t.Fatalf("verify mismatch status = %s, want partial", got)
+	}
+	if err := verified.Err(); err == nil {
+		t.Fatal("verify mismatch: Err() = nil")
+	}
+}
+
+func TestFormatFailure(t *testing.T) {
+	var stderr strings.Builder
+	for i := 1; i <= 15; i++ {
+		fmt.Fprintf(&stderr, "line %d\n", i)
+	}
+	cmdErr := &CommandError{Name: "pg_dump", Err: errors.New("exit status 1"), Stderr: stderr.String()}
+	text := FormatFailure("nightly", "2026-03-10_08-00-00", []*StepError{
+		{Step: StepDump, Item: "app", Err: fmt.Errorf("выгрузка: %w", cmdErr)},
+	})
+
+	for _, want := range []string{
+		"Ошибка резервного копирования nightly: 2026-03-10_08-00-00",
+		"Шаг: дамп базы",
+		"Элемент: app",
+		"Ошибка: выгрузка: ошибка выполнения pg_dump: exit status 1",
+		"line 6\n",
+		"line 15\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("no %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "line 5\n") {
+		t.Errorf("stderr tail is too long:\n%s", text)
+	}
+}
+
+func TestFormatFailureVerify(t *testing.T) {
+	cmdErr := &CommandError{Name: "pg_restore", Err: errors.New("exit status 1"), Stderr: "pg_restore: error: relation missing\n"}
+	verify := verifyFailed(fmt.Errorf("восстановление: %w", cmdErr))
+	text := FormatFailure("", "2026-03-10_08-00-00", []*StepError{{Step: StepVerify, Item: "app", Err: verify.Err()}})
+	if !strings.Contains(text, "Ошибка: восстановление: ошибка выполнения pg_restore: exit status 1\n") ||
+		!strings.Contains(text, "stderr:\npg_restore: error: relation missing") {
+		t.Fatalf("уведомление:\n%s", text)
+	}
+}
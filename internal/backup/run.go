@@ -1,6 +1,8 @@
 package backup
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,130 +11,415 @@ import (
 
 	"tgdump/internal/archive"
 	"tgdump/internal/config"
+	"tgdump/internal/crypt"
+	"tgdump/internal/retention"
+	"tgdump/internal/runstate"
 	"tgdump/internal/telegram"
 )
 
-func Run(cfg *config.Config) error {
-	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	archiveDir := filepath.Join(cfg.DumpDir, timestamp)
-	sendDir := filepath.Join(cfg.DumpDir, timestamp+"_telegram")
+// ErrSkipped возвращается Run, если задание пропущено из-за ещё не
+// завершившегося предыдущего запуска.
+var ErrSkipped = errors.New("запуск пропущен")
+
+// Run выполняет резервное копирование задания и при успехе запоминает время
+// запуска для догоняющего запуска после простоя. Запуск части элементов или
+// без отправки в Telegram плановый запуск не заменяет и не запоминается. Пересекающиеся запуски одного
+// задания пропускаются с уведомлением в Telegram. Отмена ctx останавливает
+// дочерние процессы и загрузки, рабочие каталоги удаляются.
+func Run(ctx context.Context, cfg *config.Config) error {
+	lock, err := runstate.Lock(cfg.DumpDir, cfg.JobName)
+	if err != nil {
+		var locked *runstate.LockedError
+		if !errors.As(err, &locked) {
+			return err
+		}
+		reportSkipped(ctx, cfg, locked)
+		return fmt.Errorf("%w: %v", ErrSkipped, locked)
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			log.Printf("%v", err)
+		}
+	}()
+
+	started := time.Now()
+	report := Report{
+		Job:       cfg.JobName,
+		Timestamp: time.Now().In(cfg.Location()).Format(retention.TimestampLayout),
+	}
+	err = run(ctx, cfg, &report)
+	if len(report.StepErrors) > 0 && !cfg.NoDelivery {
+		notifyFailure(ctx, cfg, report.Timestamp, report.StepErrors)
+	}
+	if err != nil {
+		return err
+	}
+	if cfg.Partial || cfg.NoDelivery {
+		return nil
+	}
+	if err := runstate.RecordSuccess(cfg.DumpDir, cfg.JobName, started); err != nil {
+		log.Printf("не удалось сохранить время запуска: %v", err)
+	}
+	return nil
+}
+
+func reportSkipped(ctx context.Context, cfg *config.Config, locked *runstate.LockedError) {
+	if cfg.NoDelivery {
+		return
+	}
+	text := fmt.Sprintf("Резервная копия пропущена: %v", locked)
+	if cfg.JobName != "" {
+		text = fmt.Sprintf("Резервная копия %s пропущена: %v", cfg.JobName, locked)
+	}
+	tg := telegram.NewClient(cfg.Telegram.APIURL, cfg.Telegram.Token)
+	if err := tg.SendMessage(ctx, cfg.Telegram.ChatID, text); err != nil {
+		log.Printf("не удалось отправить уведомление о пропуске: %v", err)
+	}
+}
+
+// run выполняет задание, заполняя report; ошибки шагов собираются в
+// report.StepErrors.
+func run(ctx context.Context, cfg *config.Config, report *Report) error {
+	timestamp := report.Timestamp
+	archiveDir := filepath.Join(cfg.DumpDir, archivePrefix(cfg)+timestamp)
+	sendDir := archiveDir + "_telegram"
 
 	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
-		return fmt.Errorf("не удалось создать каталог дампа: %w", err)
+		return report.fail(StepPrepare, "", fmt.Errorf("не удалось создать каталог дампа: %w", err))
 	}
 	if err := os.MkdirAll(sendDir, 0o755); err != nil {
-		return fmt.Errorf("не удалось создать каталог для отправки: %w", err)
+		return report.fail(StepPrepare, "", fmt.Errorf("не удалось создать каталог для отправки: %w", err))
 	}
 	defer func() {
 		_ = os.RemoveAll(archiveDir)
 		_ = os.RemoveAll(sendDir)
 	}()
 
-	report := Report{Timestamp: timestamp}
+	archiveManifest := newManifest(cfg.JobName, timestamp)
+	archiveManifest.Config = sanitizedConfig(cfg)
+	sendManifest := newManifest(cfg.JobName, timestamp)
 
-	for _, db := range cfg.Databases {
-		outFile := filepath.Join(archiveDir, db.DBName+".sql")
-		stats, err := DumpDatabaseEx(db, outFile)
-		if err != nil {
-			return err
-		}
-		report.Databases = append(report.Databases, DatabaseReport{
-			Name:     db.DBName,
-			Delivery: db.Delivery,
-			Tables:   stats,
-		})
-		if db.Delivery.ShouldSend() {
-			if err := CopyFile(outFile, filepath.Join(sendDir, db.DBName+".sql")); err != nil {
-				return fmt.Errorf("копирование дампа для отправки %s: %w", db.DBName, err)
-			}
+	interrupted := func() error {
+		if err := ctx.Err(); err != nil {
+			return report.fail(StepShutdown, "", fmt.Errorf("запуск прерван: %w", err))
 		}
+		return nil
 	}
 
-	dirReports, fileReports, err := copyAssets(cfg.FilesDir, cfg.Files, cfg.Directories, archiveDir, sendDir)
-	if err != nil {
+	outcomes := dumpDatabases(ctx, cfg.Databases, archiveDir, cfg.Parallel)
+	if err := interrupted(); err != nil {
 		return err
 	}
+	for i, db := range cfg.Databases {
+		dbReport := backupDatabase(ctx, cfg, db, outcomes[i], archiveDir, sendDir, &archiveManifest, &sendManifest, report)
+		report.Databases = append(report.Databases, dbReport)
+	}
+	dirReports, fileReports, pending := copyAssets(ctx, cfg, archiveDir, sendDir, &archiveManifest, &sendManifest, report)
 	report.Directories = dirReports
 	report.Files = fileReports
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	if archiveManifest.Empty() {
+		log.Printf("ни один элемент не удалось сохранить, архив не создаётся")
+	} else if err := finishArchive(ctx, cfg, archiveDir, archiveManifest, pending, report); err != nil {
+		if err := interrupted(); err != nil {
+			return err
+		}
+		report.fail(StepArchive, "", err)
+		report.Error = err.Error()
+	}
 
-	zipPath, err := archive.ZipDirectory(archiveDir)
+	if cfg.NoDelivery {
+		log.Printf("отправка в Telegram отключена, отчёт:\n%s", report.Format())
+		return report.Err()
+	}
+	tg := telegram.NewClient(cfg.Telegram.APIURL, cfg.Telegram.Token)
+	if err := tg.SendMessage(ctx, cfg.Telegram.ChatID, report.Format()); err != nil {
+		return report.fail(StepSend, "отчёт", err)
+	}
+
+	// Каталог отправки собирается отдельно от локального архива, поэтому
+	// отправляется, даже если локальный архив создать не удалось.
+	sendManifest.Config = sanitizedConfig(sentItems(cfg, sendManifest))
+	if sendManifest.Empty() {
+		log.Printf("нет элементов с delivery=send, архив в Telegram не отправляется")
+	} else if err := archive.WriteManifest(sendDir, sendManifest); err != nil {
+		return report.fail(StepSend, "архив", err)
+	} else if err := sendArchive(ctx, cfg, tg, sendDir); err != nil {
+		return report.fail(StepSend, "архив", err)
+	}
+	return report.Err()
+}
+
+// finishArchive создаёт локальный архив, сохраняет состояние инкрементальных
+// каталогов и применяет политику хранения. Ошибка возвращается, только если
+// архив не создан; ошибки состояния и очистки записываются в отчёт отдельно.
+func finishArchive(ctx context.Context, cfg *config.Config, archiveDir string, manifest archive.Manifest, pending []pendingState, report *Report) error {
+	if err := archive.WriteManifest(archiveDir, manifest); err != nil {
+		return err
+	}
+
+	zipPath, err := archive.ZipDirectory(ctx, archiveDir)
 	if err != nil {
 		return fmt.Errorf("создание архива: %w", err)
 	}
+	if cfg.Encryption.Enabled() && cfg.Encryption.Local {
+		encPath, err := crypt.EncryptFile(ctx, zipPath, cfg.Encryption)
+		if err != nil {
+			_ = os.Remove(zipPath)
+			return fmt.Errorf("шифрование архива: %w", err)
+		}
+		if err := os.Remove(zipPath); err != nil {
+			_ = os.Remove(encPath)
+			return fmt.Errorf("удаление незашифрованного архива: %w", err)
+		}
+		zipPath = encPath
+	}
 	log.Printf("архив сохранён: %s", zipPath)
 
-	if err := telegram.SendMessage(cfg.Telegram.Token, cfg.Telegram.ChatID, report.Format()); err != nil {
-		return fmt.Errorf("отправка отчёта: %w", err)
+	for _, p := range pending {
+		if err := p.save(); err != nil {
+			report.fail(StepState, "", err)
+		}
 	}
+	applyRetention(cfg, manifest, report)
+	return nil
+}
 
-	hasSend, err := dirHasFiles(sendDir)
+// applyRetention удаляет старые архивы задания, не разрывая цепочки
+// инкрементов, и сохраняет обновлённые цепочки. Частичные архивы лежат под
+// своим префиксом и на архивы задания не влияют.
+func applyRetention(cfg *config.Config, manifest archive.Manifest, report *Report) {
+	if cfg.Partial {
+		return
+	}
+	chains, err := runstate.Chains(cfg.DumpDir, cfg.JobName)
 	if err != nil {
-		return err
+		// Без цепочек можно удалить полную копию, от которой зависят инкременты.
+		report.fail(StepRetention, "", err)
+		return
 	}
-	if !hasSend {
-		log.Printf("нет элементов с delivery=send, архив в Telegram не отправляется")
-		return nil
+	if base := chainBase(manifest); base != "" {
+		chains[manifest.Timestamp] = base
+	}
+	pruned, err := retention.Apply(cfg.DumpDir, archivePrefix(cfg), cfg.Retention, cfg.Location(), chains)
+	for _, a := range pruned {
+		log.Printf("удалён старый архив: %s", a.Path)
+		report.Pruned = append(report.Pruned, filepath.Base(a.Path))
+		delete(chains, a.Time.Format(retention.TimestampLayout))
+	}
+	if err != nil {
+		report.fail(StepRetention, "", err)
+	}
+	if err := runstate.SaveChains(cfg.DumpDir, cfg.JobName, chains); err != nil {
+		report.fail(StepState, "", err)
 	}
+}
 
-	return telegram.SendFolder(cfg.Telegram.Token, cfg.Telegram.ChatID, sendDir, false)
+// chainBase возвращает метку самого раннего архива с полной копией, от которого
+// зависят инкрементальные каталоги манифеста; пусто, если архив самодостаточен.
+func chainBase(m archive.Manifest) string {
+	var base string
+	for _, d := range m.Directories {
+		// Метки времени в формате TimestampLayout сравниваются как строки.
+		if d.Incremental && (base == "" || d.Base < base) {
+			base = d.Base
+		}
+	}
+	return base
 }
 
-func dirHasFiles(dir string) (bool, error) {
-	var found bool
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// backupDatabase проверяет выгруженную базу и добавляет её в манифесты. Ошибка
+// записывается в отчёт базы, остальные элементы продолжают сохраняться.
+func backupDatabase(ctx context.Context, cfg *config.Config, db config.DumpConfig, dumped dumpOutcome, archiveDir, sendDir string, archiveManifest, sendManifest *archive.Manifest, report *Report) DatabaseReport {
+	dbReport := DatabaseReport{Name: db.DBName, Delivery: db.Delivery, Format: db.Format}
+	fail := func(step string, err error) DatabaseReport {
+		dbReport.Error = report.fail(step, db.DBName, err).Err.Error()
+		return dbReport
+	}
+
+	if dumped.Err != nil {
+		return fail(StepDump, dumped.Err)
+	}
+	result := dumped.Result
+	dbReport.SizeMB = float64(dumped.Size) / bytesPerMB
+	dbReport.Tables = result.Tables
+	dbReport.Masked = result.Masked
+
+	entry := manifestDatabase(db, result.Paths, result.Tables)
+	if cfg.Verify.Enabled {
+		verify := verifyDump(ctx, cfg.Verify, db, entry, archiveDir, result.Tables)
+		dbReport.Verify = &verify
+		if verify.Status != VerifyOK {
+			_ = report.fail(StepVerify, db.DBName, verify.Err())
 		}
-		if !info.IsDir() {
-			found = true
-			return filepath.SkipAll
+	}
+	archiveManifest.Databases = append(archiveManifest.Databases, entry)
+	if db.Delivery.ShouldSend() {
+		for _, path := range result.Paths {
+			dst := filepath.Join(sendDir, filepath.Base(path))
+			if err := CopyPath(ctx, path, dst); err != nil {
+				_ = os.RemoveAll(dst)
+				return fail(StepCopy, fmt.Errorf("копирование дампа для отправки: %w", err))
+			}
 		}
-		return nil
-	})
-	return found, err
+		sendManifest.Databases = append(sendManifest.Databases, entry)
+	}
+	return dbReport
+}
+
+const cleanupTimeout = 30 * time.Second
+
+// cleanupContext возвращает контекст для уборки и уведомлений, который
+// не отменяется вместе с ctx запуска.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// PartialPrefix — префикс архивов, в которых выбрана только часть элементов
+// задания. Политика хранения и догоняющий запуск их не учитывают.
+const PartialPrefix = "partial_"
+
+// archivePrefix возвращает префикс имён архивов задания: "<job>_" или пусто,
+// с PartialPrefix для частичного запуска.
+func archivePrefix(cfg *config.Config) string {
+	var prefix string
+	if cfg.JobName != "" {
+		prefix = cfg.JobName + "_"
+	}
+	if cfg.Partial {
+		prefix = PartialPrefix + prefix
+	}
+	return prefix
 }
 
-func copyAssets(filesDir string, files, dirs config.AssetList, archiveDir, sendDir string) ([]DirectoryReport, []FileReport, error) {
+// copyAssets копирует файлы и каталоги в архив и каталог отправки. Ошибки
+// записываются в отчёт элемента, остальные элементы продолжают копироваться.
+func copyAssets(ctx context.Context, cfg *config.Config, archiveDir, sendDir string, archiveManifest, sendManifest *archive.Manifest, report *Report) ([]DirectoryReport, []FileReport, []pendingState) {
+	filesDir := cfg.FilesDir
 	var dirReports []DirectoryReport
 	var fileReports []FileReport
+	var pending []pendingState
 
-	for _, entry := range files {
+	for _, entry := range cfg.Files {
+		if ctx.Err() != nil {
+			break
+		}
 		src := filepath.Join(filesDir, entry.Path)
 		name := filepath.Base(entry.Path)
 		archiveDst := filepath.Join(archiveDir, name)
+		fileReport := FileReport{Name: name, Delivery: entry.Delivery}
 		log.Printf("копирование файла %s -> %s", src, archiveDst)
-		if err := CopyFile(src, archiveDst); err != nil {
-			return nil, nil, fmt.Errorf("копирование файла %s: %w", src, err)
+		if err := CopyFile(ctx, src, archiveDst); err != nil {
+			_ = os.Remove(archiveDst)
+			fileReport.Error = report.fail(StepCopy, entry.Path, fmt.Errorf("копирование файла %s: %w", src, err)).Err.Error()
+			fileReports = append(fileReports, fileReport)
+			continue
 		}
-		fileReports = append(fileReports, FileReport{Name: name, Delivery: entry.Delivery})
+		asset := archive.ManifestAsset{Path: filepath.ToSlash(entry.Path), Archive: name}
+		archiveManifest.Files = append(archiveManifest.Files, asset)
 		if entry.Delivery.ShouldSend() {
-			if err := CopyFile(src, filepath.Join(sendDir, name)); err != nil {
-				return nil, nil, fmt.Errorf("копирование файла для отправки %s: %w", src, err)
+			sendDst := filepath.Join(sendDir, name)
+			if err := CopyFile(ctx, src, sendDst); err != nil {
+				_ = os.Remove(sendDst)
+				fileReport.Error = report.fail(StepCopy, entry.Path, fmt.Errorf("копирование файла для отправки %s: %w", src, err)).Err.Error()
+			} else {
+				sendManifest.Files = append(sendManifest.Files, asset)
 			}
 		}
+		fileReports = append(fileReports, fileReport)
 	}
 
-	for _, entry := range dirs {
+	for _, entry := range cfg.Directories {
+		if ctx.Err() != nil {
+			break
+		}
 		src := filepath.Join(filesDir, entry.Path)
 		name := filepath.Base(entry.Path)
 		archiveDst := filepath.Join(archiveDir, name)
 
-		stat, err := collectDirectoryStats(src, name)
+		stat, asset, state, err := copyDirectory(ctx, cfg, entry, src, archiveDst, archiveManifest.Timestamp)
+		stat.Name = name
+		stat.Delivery = entry.Delivery
 		if err != nil {
-			return nil, nil, err
+			_ = os.RemoveAll(archiveDst)
+			stat.Error = report.fail(StepCopy, entry.Path, err).Err.Error()
+			dirReports = append(dirReports, stat)
+			continue
 		}
-		stat.Delivery = entry.Delivery
-		dirReports = append(dirReports, stat)
-
-		log.Printf("копирование каталога %s -> %s", src, archiveDst)
-		if err := CopyDir(src, archiveDst); err != nil {
-			return nil, nil, fmt.Errorf("копирование каталога %s: %w", src, err)
+		if state != nil {
+			pending = append(pending, *state)
 		}
+
+		archiveManifest.Directories = append(archiveManifest.Directories, asset)
 		if entry.Delivery.ShouldSend() {
-			if err := CopyDir(src, filepath.Join(sendDir, name)); err != nil {
-				return nil, nil, fmt.Errorf("копирование каталога для отправки %s: %w", src, err)
+			// В Telegram каталог всегда уходит полной копией: цепочку
+			// инкрементов из чата не восстановить.
+			sendDst := filepath.Join(sendDir, name)
+			sendSrc, sendAsset := archiveDst, asset
+			if asset.Incremental {
+				sendSrc = src
+				sendAsset = archive.ManifestAsset{Path: asset.Path, Archive: asset.Archive}
+			}
+			if err := CopyDir(ctx, sendSrc, sendDst); err != nil {
+				_ = os.RemoveAll(sendDst)
+				stat.Error = report.fail(StepCopy, entry.Path, fmt.Errorf("копирование каталога для отправки %s: %w", src, err)).Err.Error()
+			} else {
+				sendManifest.Directories = append(sendManifest.Directories, sendAsset)
 			}
 		}
+		dirReports = append(dirReports, stat)
+	}
+	return dirReports, fileReports, pending
+}
+
+// copyDirectory копирует каталог в архив полностью или инкрементально.
+// Для инкрементального каталога возвращается состояние для сохранения;
+// timestamp — метка создаваемого архива.
+func copyDirectory(ctx context.Context, cfg *config.Config, entry config.AssetEntry, src, archiveDst, timestamp string) (DirectoryReport, archive.ManifestAsset, *pendingState, error) {
+	name := filepath.Base(entry.Path)
+	asset := archive.ManifestAsset{Path: filepath.ToSlash(entry.Path), Archive: name}
+	// Частичный архив не входит в цепочки инкрементов, поэтому каталог в нём
+	// всегда полный, а состояние не меняется.
+	if !entry.Incremental || cfg.Partial {
+		stat, err := collectDirectoryStats(src, name)
+		if err != nil {
+			return stat, asset, nil, err
+		}
+		log.Printf("копирование каталога %s -> %s", src, archiveDst)
+		if err := CopyDir(ctx, src, archiveDst); err != nil {
+			return stat, asset, nil, fmt.Errorf("копирование каталога %s: %w", src, err)
+		}
+		return stat, asset, nil, nil
+	}
+
+	statePath := incrementalStatePath(cfg.DumpDir, cfg.JobName, entry.Path)
+	prev, err := loadDirState(statePath)
+	if err != nil {
+		return DirectoryReport{}, asset, nil, err
+	}
+	if prev.Base == "" {
+		// Неизвестно, в каком архиве полная копия: начинаем новую цепочку.
+		prev = dirState{}
+	}
+	log.Printf("инкрементальное копирование каталога %s -> %s", src, archiveDst)
+	res, err := copyIncremental(ctx, src, archiveDst, name, prev, entry.FullEvery)
+	if err != nil {
+		return DirectoryReport{}, asset, nil, err
+	}
+	if res.Full {
+		res.State.Base = timestamp
+	} else {
+		res.State.Base = prev.Base
 	}
-	return dirReports, fileReports, nil
+	stat := res.Report
+	stat.Incremental = !res.Full
+	stat.Changed = res.Changed
+	stat.Deleted = len(res.Deleted)
+	asset.Incremental = !res.Full
+	asset.Base = res.State.Base
+	asset.Deleted = res.Deleted
+	return stat, asset, &pendingState{path: statePath, state: res.State}, nil
 }
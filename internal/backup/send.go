@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+	"tgdump/internal/crypt"
+	"tgdump/internal/telegram"
+)
+
+// sendArchive архивирует каталог отправки, при включённом шифровании шифрует
+// архив и отправляет его в Telegram. Временные файлы удаляются.
+func sendArchive(ctx context.Context, cfg *config.Config, tg *telegram.Client, sendDir string) error {
+	log.Printf("создание архива для отправки: %s", sendDir)
+	zipPath, err := archive.ZipDirectory(ctx, sendDir)
+	if err != nil {
+		return fmt.Errorf("создание архива для отправки: %w", err)
+	}
+	defer removeTemp(zipPath)
+
+	sendPath := zipPath
+	if cfg.Encryption.Enabled() {
+		sendPath, err = crypt.EncryptFile(ctx, zipPath, cfg.Encryption)
+		if err != nil {
+			return fmt.Errorf("шифрование архива для отправки: %w", err)
+		}
+		defer removeTemp(sendPath)
+	}
+
+	info, err := os.Stat(sendPath)
+	if err != nil {
+		return fmt.Errorf("ошибка получения информации о файле: %w", err)
+	}
+	log.Printf("размер архива для отправки: %d bytes", info.Size())
+
+	if info.Size() <= cfg.Telegram.MaxFileBytes() {
+		if err := tg.SendFile(ctx, cfg.Telegram.ChatID, sendPath, ""); err != nil {
+			return fmt.Errorf("ошибка отправки архива: %w", err)
+		}
+		return nil
+	}
+	return sendParts(ctx, cfg.Telegram, tg, sendPath)
+}
+
+// sendParts режет архив на части по лимиту Telegram и отправляет их вместе
+// с файлом контрольной суммы для команды join.
+func sendParts(ctx context.Context, tcfg config.TelegramConfig, tg *telegram.Client, path string) error {
+	sum, err := archive.SHA256File(path)
+	if err != nil {
+		return err
+	}
+	parts, err := archive.Split(ctx, path, tcfg.MaxFileBytes())
+	for _, part := range parts {
+		defer removeTemp(part)
+	}
+	if err != nil {
+		return fmt.Errorf("разбиение архива на части: %w", err)
+	}
+	checksumPath, err := archive.WriteChecksum(path, sum)
+	if err != nil {
+		return err
+	}
+	defer removeTemp(checksumPath)
+
+	log.Printf("архив больше %d МБ, отправка %d частями", tcfg.MaxFileMB, len(parts))
+	for i, part := range parts {
+		caption := fmt.Sprintf("%s part %d/%d", filepath.Base(path), i+1, len(parts))
+		if err := tg.SendFile(ctx, tcfg.ChatID, part, caption); err != nil {
+			return fmt.Errorf("ошибка отправки части %d/%d: %w", i+1, len(parts), err)
+		}
+	}
+	caption := fmt.Sprintf("sha256 %s, собрать: tgdump join %s", sum, filepath.Base(parts[0]))
+	if err := tg.SendFile(ctx, tcfg.ChatID, checksumPath, caption); err != nil {
+		return fmt.Errorf("ошибка отправки контрольной суммы: %w", err)
+	}
+	return nil
+}
+
+func removeTemp(path string) {
+	if err := os.Remove(path); err != nil {
+		log.Printf("не удалось удалить временный архив: %v", err)
+	}
+}
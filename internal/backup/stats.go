@@ -1,64 +1,104 @@
 package backup
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"tgdump/internal/config"
 )
 
+const bytesPerMB = 1024 * 1024
+
 func quoteIdent(name string) string {
 	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
 }
 
-func listPublicTables(db *sql.DB) ([]string, error) {
-	rows, err := db.Query(`
-		SELECT tablename
-		FROM pg_tables
-		WHERE schemaname = 'public'
-		ORDER BY tablename`)
+func quoteTable(t config.TableRef) string {
+	return quoteIdent(t.Schema) + "." + quoteIdent(t.Table)
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// queryer — *sql.DB или *sql.Tx, если запросы должны видеть снимок дампа.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// listTables возвращает пользовательские таблицы из выгружаемых схем.
+// Временные таблицы других сессий недоступны и в дамп не попадают.
+// Секционированные таблицы сами строк не хранят: их данные лежат в секциях,
+// которые входят в список как обычные таблицы, иначе строки считались бы
+// дважды.
+func listTables(ctx context.Context, db queryer, cfg config.DumpConfig) ([]config.TableRef, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.nspname, c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'r'
+		  AND c.relpersistence <> 't'
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		  AND n.nspname NOT LIKE 'pg\_toast%'
+		ORDER BY n.nspname, c.relname`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tables []string
+	var tables []config.TableRef
 	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
+		var t config.TableRef
+		if err := rows.Scan(&t.Schema, &t.Table); err != nil {
 			return nil, err
 		}
-		tables = append(tables, name)
+		if cfg.SchemaSelected(t.Schema) {
+			tables = append(tables, t)
+		}
 	}
 	return tables, rows.Err()
 }
 
-func countTableRows(db *sql.DB, table string) (int64, error) {
+func countTableRows(ctx context.Context, db queryer, table config.TableRef, filter string) (int64, error) {
 	var n int64
-	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, quoteIdent(table))
-	if err := db.QueryRow(query).Scan(&n); err != nil {
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, quoteTable(table))
+	if filter != "" {
+		query += " WHERE (" + filter + ")"
+	}
+	if err := db.QueryRowContext(ctx, query).Scan(&n); err != nil {
 		return 0, err
 	}
 	return n, nil
 }
 
-func collectDumpedTableStats(db *sql.DB, excluded map[string][]string) ([]TableRowCount, error) {
-	tables, err := listPublicTables(db)
+// collectDumpedTableStats считает строки, попадающие в дамп, с учётом фильтров.
+func collectDumpedTableStats(ctx context.Context, db queryer, cfg config.DumpConfig, rulesMap map[config.TableRef]*tableRules) ([]TableRowCount, error) {
+	tables, err := listTables(ctx, db, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось получить список таблиц: %w", err)
 	}
 
 	var stats []TableRowCount
 	for _, table := range tables {
-		if _, skip := excluded[table]; skip {
-			continue
+		var filter string
+		if rules, ok := rulesMap[table]; ok {
+			filter = rules.Filter
 		}
-		rows, err := countTableRows(db, table)
+		rows, err := countTableRows(ctx, db, table, filter)
 		if err != nil {
 			return nil, fmt.Errorf("не удалось посчитать строки в %s: %w", table, err)
 		}
-		stats = append(stats, TableRowCount{Name: table, Rows: rows})
+		stats = append(stats, TableRowCount{
+			Schema:   table.Schema,
+			Name:     table.Table,
+			Rows:     rows,
+			Filtered: filter != "",
+		})
 	}
 	return stats, nil
 }
@@ -82,10 +122,29 @@ func collectDirectoryStats(root, displayName string) (DirectoryReport, error) {
 		return DirectoryReport{}, fmt.Errorf("не удалось просканировать каталог %s: %w", root, err)
 	}
 
-	const bytesPerMB = 1024 * 1024
 	return DirectoryReport{
 		Name:      displayName,
 		FileCount: fileCount,
 		SizeMB:    float64(sizeBytes) / bytesPerMB,
 	}, nil
 }
+
+// pathsSize возвращает суммарный размер файлов и каталогов в байтах.
+func pathsSize(paths []string) (int64, error) {
+	var total int64
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				total += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("не удалось определить размер %s: %w", root, err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+	"tgdump/internal/restore"
+)
+
+type VerifyStatus string
+
+const (
+	VerifyOK       VerifyStatus = "verified"
+	VerifyMismatch VerifyStatus = "mismatch"
+	VerifyFailed   VerifyStatus = "error"
+)
+
+type TableMismatch struct {
+	Table    string
+	Expected int64
+	Actual   int64 // -1, если таблицы нет после восстановления
+}
+
+type VerifyResult struct {
+	Status     VerifyStatus
+	Mismatches []TableMismatch
+	Error      string
+
+	err error // исходная ошибка со stderr команды для уведомления
+}
+
+func verifyFailed(err error) VerifyResult {
+	return VerifyResult{Status: VerifyFailed, Error: err.Error(), err: err}
+}
+
+// Err описывает неуспешную проверку как ошибку; nil при успехе.
+func (v VerifyResult) Err() error {
+	switch v.Status {
+	case VerifyOK:
+		return nil
+	case VerifyMismatch:
+		return fmt.Errorf("расхождения в количестве строк в %d таблицах", len(v.Mismatches))
+	default:
+		if v.err != nil {
+			return v.err
+		}
+		return errors.New(v.Error)
+	}
+}
+
+var unsafeDBNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// scratchDBName возвращает имя временной базы для проверки. Случайный суффикс
+// не даёт пересекающимся запускам и одноимённым базам удалить чужую временную
+// базу; лимит PostgreSQL — 63 байта.
+func scratchDBName(dbName string) string {
+	var suffix [4]byte
+	_, _ = rand.Read(suffix[:])
+	name := "tgdump_verify_" + unsafeDBNameChars.ReplaceAllString(strings.ToLower(dbName), "_")
+	if maxLen := 63 - 1 - 2*len(suffix); len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	return name + "_" + hex.EncodeToString(suffix[:])
+}
+
+// verifyDump восстанавливает дамп во временную базу сервера проверки и сравнивает
+// количество строк с собранной при дампе статистикой. Временная база удаляется.
+func verifyDump(ctx context.Context, vcfg config.VerifyConfig, db config.DumpConfig, entry archive.ManifestDatabase, dir string, expected []TableRowCount) VerifyResult {
+	target := db
+	target.Host = vcfg.Host
+	target.Port = vcfg.Port
+	target.User = vcfg.User
+	target.Password = vcfg.Password
+	target.DBName = scratchDBName(db.DBName)
+
+	log.Printf("проверка восстановления %s в %s", db.DBName, target.DBName)
+	defer func() {
+		// Временную базу удаляем и после отмены запуска.
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if err := restore.DropDatabase(cleanupCtx, target); err != nil {
+			log.Printf("не удалось удалить временную базу %s: %v", target.DBName, err)
+		}
+	}()
+
+	if err := restore.RestoreDump(ctx, target, entry, dir, restore.DumpOptions{Drop: true, Jobs: db.Jobs}); err != nil {
+		return verifyFailed(err)
+	}
+
+	actual, err := countRestoredRows(ctx, target)
+	if err != nil {
+		return verifyFailed(err)
+	}
+
+	result := VerifyResult{Status: VerifyOK}
+	for _, t := range expected {
+		name := t.Schema + "." + t.Name
+		rows, ok := actual[name]
+		if !ok {
+			rows = -1
+		}
+		if rows != t.Rows {
+			result.Mismatches = append(result.Mismatches, TableMismatch{Table: name, Expected: t.Rows, Actual: rows})
+		}
+	}
+	if len(result.Mismatches) > 0 {
+		result.Status = VerifyMismatch
+	}
+	return result
+}
+
+func countRestoredRows(ctx context.Context, target config.DumpConfig) (map[string]int64, error) {
+	db, err := openDB(target)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к временной базе: %w", err)
+	}
+	defer db.Close()
+
+	tables, err := listTables(ctx, db, target)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список таблиц: %w", err)
+	}
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		n, err := countTableRows(ctx, db, table, "")
+		if err != nil {
+			return nil, fmt.Errorf("не удалось посчитать строки в %s: %w", table, err)
+		}
+		counts[table.String()] = n
+	}
+	return counts, nil
+}
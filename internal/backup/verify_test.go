@@ -0,0 +1,19 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScratchDBName(t *testing.T) {
+	a, b := scratchDBName("Shop"), scratchDBName("Shop")
+	if a == b {
+		t.Fatalf("одинаковые имена временных баз: %s", a)
+	}
+	if !strings.HasPrefix(a, "tgdump_verify_shop_") {
+		t.Fatalf("имя: %s", a)
+	}
+	if long := scratchDBName(strings.Repeat("x", 100)); len(long) > 63 {
+		t.Fatalf("длина %d: %s", len(long), long)
+	}
+}
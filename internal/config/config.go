@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,41 +13,160 @@ import (
 const (
 	defaultFilesDir = "./files"
 	defaultSchedule = "08:00"
+	// Bot API принимает документы до 50 МБ, оставляем запас на multipart.
+	defaultMaxFileMB = 49
+	defaultFullEvery = 7
 )
 
 type DumpConfig struct {
-	Host     string   `yaml:"host"`
-	Port     string   `yaml:"port"`
-	User     string   `yaml:"user"`
-	Password string   `yaml:"password"`
-	DBName   string   `yaml:"name"`
-	Exclude  []string `yaml:"exclude"`
-	Delivery Delivery `yaml:"delivery"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"name"`
+
+	PasswordFile string `yaml:"password_file"` // файл с паролем, например Docker secret
+	PassFile     string `yaml:"passfile"`      // .pgpass для базы, передаётся как PGPASSFILE
+
+	Exclude []string          `yaml:"exclude"`
+	Mask    map[string]string `yaml:"mask"`
+	Filters map[string]string `yaml:"filters"`
+
+	Schemas        []string `yaml:"schemas"`
+	ExcludeSchemas []string `yaml:"exclude_schemas"`
+
+	Delivery Delivery   `yaml:"delivery"`
+	Format   DumpFormat `yaml:"format"`
+	Jobs     int        `yaml:"jobs"`
+}
+
+type TelegramConfig struct {
+	APIURL    string `yaml:"api_url"` // по умолчанию https://api.telegram.org
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"`
+	ChatID    string `yaml:"chat_id"`
+	AlertChat string `yaml:"alert_chat_id"` // чат для уведомлений об ошибках, по умолчанию chat_id
+	MaxFileMB int    `yaml:"max_file_mb"`   // архивы больше режутся на части
+}
+
+// AlertChatID возвращает чат для уведомлений об ошибках.
+func (t TelegramConfig) AlertChatID() string {
+	if t.AlertChat != "" {
+		return t.AlertChat
+	}
+	return t.ChatID
+}
+
+// MaxFileBytes возвращает лимит размера одного документа в байтах.
+func (t TelegramConfig) MaxFileBytes() int64 {
+	return int64(t.MaxFileMB) * 1024 * 1024
+}
+
+// VerifyConfig — сервер для пробного восстановления дампов после запуска.
+type VerifyConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+
+	PasswordFile string `yaml:"password_file"`
+}
+
+// EncryptionConfig — шифрование архивов age: ключи X25519 получателей или пароль.
+type EncryptionConfig struct {
+	Recipients     []string `yaml:"recipients"`
+	Passphrase     string   `yaml:"passphrase"`
+	PassphraseFile string   `yaml:"passphrase_file"`
+	Local          bool     `yaml:"local"` // шифровать и архив в dump_dir
 }
 
+func (e EncryptionConfig) Enabled() bool {
+	return len(e.Recipients) > 0 || e.Passphrase != ""
+}
+
+// RetentionConfig — политика хранения архивов в dump_dir. Если все значения
+// нулевые, архивы не удаляются.
+type RetentionConfig struct {
+	KeepLast   int `yaml:"keep_last"`
+	Daily      int `yaml:"daily"`
+	Weekly     int `yaml:"weekly"`
+	Monthly    int `yaml:"monthly"`
+	MaxTotalMB int `yaml:"max_total_mb"`
+}
+
+func (r RetentionConfig) Enabled() bool {
+	return r.KeepLast > 0 || r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0 || r.MaxTotalMB > 0
+}
+
+// ParallelConfig — ограничения параллельного дампа баз: всего и на один
+// сервер (host:port). Ноль в per_host — без отдельного ограничения.
+type ParallelConfig struct {
+	Dumps   int `yaml:"dumps"`
+	PerHost int `yaml:"per_host"`
+}
+
+// RunOnStart определяет, запускать ли задания сразу при старте.
+type RunOnStart string
+
+const (
+	RunOnStartMissed RunOnStart = "missed" // только если запуск по расписанию был пропущен
+	RunOnStartAlways RunOnStart = "always"
+	RunOnStartNever  RunOnStart = "never"
+)
+
 type Config struct {
 	Databases   []DumpConfig `yaml:"databases"`
 	Directories AssetList    `yaml:"directories"`
 	Files       AssetList    `yaml:"files"`
 	FilesDir    string       `yaml:"files_dir"`
 
-	Telegram struct {
-		Token  string `yaml:"token"`
-		ChatID string `yaml:"chat_id"`
-	} `yaml:"telegram"`
+	Telegram TelegramConfig `yaml:"telegram"`
+
+	Verify     VerifyConfig     `yaml:"verify"`
+	Encryption EncryptionConfig `yaml:"encryption"`
+	Retention  RetentionConfig  `yaml:"retention"`
+	Parallel   ParallelConfig   `yaml:"parallel"`
+
+	DumpDir  string       `yaml:"dump_dir"`
+	Schedule ScheduleList `yaml:"schedule"`
+	Timezone string       `yaml:"timezone"` // для расписаний и имён архивов, по умолчанию локальный
+	Jobs     []JobConfig  `yaml:"jobs"`
+
+	RunOnStart    RunOnStart    `yaml:"run_on_start"`
+	ShutdownGrace time.Duration `yaml:"shutdown_grace"` // ожидание текущего запуска при остановке
 
-	DumpDir  string `yaml:"dump_dir"`
-	Schedule string `yaml:"schedule"`
+	// JobName — имя задания после ResolveJobs; пусто для конфигурации без jobs.
+	JobName string `yaml:"-"`
+	// NoDelivery — ничего не отправлять в Telegram (флаг командной строки).
+	NoDelivery bool `yaml:"-"`
+	// Partial — в командной строке выбрана только часть элементов задания.
+	Partial bool `yaml:"-"`
+
+	location *time.Location
+	lines    map[string]int // строки YAML по пути параметра, для ошибок
+}
+
+// Location возвращает часовой пояс из timezone.
+func (c *Config) Location() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
 }
 
 func (c *Config) Print() {
 	fmt.Println("Databases:")
 	for _, db := range c.Databases {
-		fmt.Printf("  - %s [%s]\n", db.DBName, db.Delivery.Label())
+		fmt.Printf("  - %s (%s) [%s]\n", db.DBName, db.Format, db.Delivery.Label())
 	}
 	fmt.Println("Directories:")
 	for _, dir := range c.Directories {
-		fmt.Printf("  - %s [%s]\n", dir.Path, dir.Delivery.Label())
+		if dir.Incremental {
+			fmt.Printf("  - %s [%s, инкрементально, полная копия раз в %d]\n", dir.Path, dir.Delivery.Label(), dir.FullEvery)
+		} else {
+			fmt.Printf("  - %s [%s]\n", dir.Path, dir.Delivery.Label())
+		}
 	}
 	fmt.Println("Files:")
 	for _, file := range c.Files {
@@ -53,42 +175,162 @@ func (c *Config) Print() {
 	fmt.Println("FilesDir:")
 	fmt.Printf("  - %s\n", c.FilesDir)
 	fmt.Println("Telegram:")
+	if c.Telegram.APIURL != "" {
+		fmt.Printf("  - APIURL: %s\n", c.Telegram.APIURL)
+	}
 	fmt.Printf("  - ChatID: %s\n", c.Telegram.ChatID)
+	fmt.Printf("  - AlertChatID: %s\n", c.Telegram.AlertChatID())
+	fmt.Printf("  - MaxFileMB: %d\n", c.Telegram.MaxFileMB)
+	if c.Verify.Enabled {
+		fmt.Println("Verify:")
+		fmt.Printf("  - %s:%s\n", c.Verify.Host, c.Verify.Port)
+	}
+	if c.Encryption.Enabled() {
+		fmt.Println("Encryption:")
+		fmt.Printf("  - получателей: %d, пароль: %t, локальный архив: %t\n",
+			len(c.Encryption.Recipients), c.Encryption.Passphrase != "", c.Encryption.Local)
+	}
 	fmt.Println("DumpDir:")
 	fmt.Printf("  - %s\n", c.DumpDir)
+	if c.Retention.Enabled() {
+		r := c.Retention
+		fmt.Println("Retention:")
+		fmt.Printf("  - последних: %d, дней: %d, недель: %d, месяцев: %d, максимум: %d МБ\n",
+			r.KeepLast, r.Daily, r.Weekly, r.Monthly, r.MaxTotalMB)
+	}
+	if c.Parallel.Dumps > 1 {
+		fmt.Println("Parallel:")
+		fmt.Printf("  - дампов: %d, на сервер: %d\n", c.Parallel.Dumps, c.Parallel.PerHost)
+	}
 	fmt.Println("Schedule:")
-	fmt.Printf("  - %s\n", c.Schedule)
+	for _, spec := range c.Schedule {
+		fmt.Printf("  - %s\n", spec)
+	}
+	if len(c.Jobs) > 0 {
+		fmt.Println("Jobs:")
+		for _, job := range c.Jobs {
+			fmt.Printf("  - %s %v: базы %v, файлы %v, каталоги %v\n",
+				job.Name, job.Schedule, job.Databases, job.Files, job.Directories)
+		}
+	}
+	fmt.Println("RunOnStart:")
+	fmt.Printf("  - %s\n", c.RunOnStart)
+	fmt.Println("ShutdownGrace:")
+	fmt.Printf("  - %s\n", c.ShutdownGrace)
+	fmt.Println("Timezone:")
+	fmt.Printf("  - %s\n", c.Location())
 }
 
+const DefaultPath = "config.yml"
+
 func Read() (*Config, error) {
-	data, err := os.ReadFile("config.yml")
-	if err != nil {
+	return ReadFile(DefaultPath)
+}
+
+// ReadFile читает конфигурацию из path с подстановкой ${VAR}, переопределяет
+// её переменными TGDUMP_* и читает секреты из *_file. Без файла конфигурация
+// целиком берётся из переменных окружения, если они заданы. Ошибки в значениях
+// возвращаются все сразу как *ValidationError.
+func ReadFile(path string) (*Config, error) {
+	var cfg Config
+	v := newValidator()
+	environ := os.Environ()
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := decodeYAML(data, &cfg, v); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, os.ErrNotExist) && len(envOverrides(environ)) > 0:
+		// Конфигурация только из переменных окружения.
+	default:
 		return nil, fmt.Errorf("ошибка чтения файла: %w", err)
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга YAML: %w", err)
+	if err := applyEnvOverrides(&cfg, environ); err != nil {
+		return nil, err
+	}
+	cfg.resolveSecrets(v)
+	v.check(&cfg)
+	if err := v.err(); err != nil {
+		return nil, err
 	}
+	cfg.lines = v.lines
 
 	normalizeConfig(&cfg)
+	if _, err := cfg.ResolveJobs(); err != nil {
+		return nil, err
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный timezone %q: %w", cfg.Timezone, err)
+		}
+		cfg.location = loc
+	}
 	return &cfg, nil
 }
 
+func decodeYAML(data []byte, cfg *Config, v *validator) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("ошибка парсинга YAML: %w", err)
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	if err := expandEnv(&root, os.LookupEnv); err != nil {
+		return err
+	}
+	// Ошибки типов не мешают разобрать остальные значения: они попадают
+	// в общий список вместе с остальными ошибками конфигурации.
+	decodeErr := root.Decode(cfg)
+	var typeErr *yaml.TypeError
+	if decodeErr != nil && !errors.As(decodeErr, &typeErr) {
+		return fmt.Errorf("ошибка парсинга YAML: %w", decodeErr)
+	}
+	v.index(&root, reflect.TypeOf(cfg), "")
+	if typeErr != nil {
+		v.typeErrors(typeErr)
+	}
+	return nil
+}
+
 func normalizeConfig(cfg *Config) {
 	if cfg.FilesDir == "" {
 		cfg.FilesDir = defaultFilesDir
 	}
-	if cfg.Schedule == "" {
-		cfg.Schedule = defaultSchedule
+	if len(cfg.Schedule) == 0 {
+		cfg.Schedule = ScheduleList{defaultSchedule}
+	}
+	if cfg.Parallel.Dumps <= 0 {
+		cfg.Parallel.Dumps = 1
+	}
+	if cfg.ShutdownGrace <= 0 {
+		cfg.ShutdownGrace = 30 * time.Second
+	}
+	switch cfg.RunOnStart {
+	case RunOnStartMissed, RunOnStartAlways, RunOnStartNever:
+	default:
+		cfg.RunOnStart = RunOnStartMissed
+	}
+	if cfg.Telegram.MaxFileMB <= 0 {
+		cfg.Telegram.MaxFileMB = defaultMaxFileMB
 	}
 	for i := range cfg.Databases {
 		cfg.Databases[i].Delivery = NormalizeDelivery(cfg.Databases[i].Delivery)
+		cfg.Databases[i].Format = NormalizeFormat(cfg.Databases[i].Format)
+		if cfg.Databases[i].Jobs < 1 {
+			cfg.Databases[i].Jobs = 1
+		}
 	}
 	for i := range cfg.Files {
 		cfg.Files[i].Delivery = NormalizeDelivery(cfg.Files[i].Delivery)
 	}
 	for i := range cfg.Directories {
 		cfg.Directories[i].Delivery = NormalizeDelivery(cfg.Directories[i].Delivery)
+		if cfg.Directories[i].Incremental && cfg.Directories[i].FullEvery <= 0 {
+			cfg.Directories[i].FullEvery = defaultFullEvery
+		}
 	}
 }
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -29,3 +33,277 @@ directories:
 		t.Fatalf("directories: %+v", cfg.Directories)
 	}
 }
+
+func TestParseMaskRule(t *testing.T) {
+	cases := map[string]MaskRule{
+		"null":         {Kind: MaskNull},
+		"hash":         {Kind: MaskHash},
+		"fixed:secret": {Kind: MaskFixed, Value: "secret"},
+		"fixed:":       {Kind: MaskFixed},
+		"fake_email":   {Kind: MaskFakeEmail},
+		"truncate":     {Kind: MaskTruncate},
+		"truncate:4":   {Kind: MaskTruncate, Length: 4},
+	}
+	for in, want := range cases {
+		got, err := ParseMaskRule(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %+v, want %+v", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "drop", "fixed", "hash:1", "truncate:-1", "truncate:x"} {
+		if _, err := ParseMaskRule(in); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
+
+func TestParseColumnRef(t *testing.T) {
+	table, col, err := ParseColumnRef("users.password")
+	if err != nil || table != (TableRef{Schema: "public", Table: "users"}) || col != "password" {
+		t.Fatalf("users.password: %+v %q %v", table, col, err)
+	}
+	table, col, err = ParseColumnRef("billing.invoices.card")
+	if err != nil || table != (TableRef{Schema: "billing", Table: "invoices"}) || col != "card" {
+		t.Fatalf("billing.invoices.card: %+v %q %v", table, col, err)
+	}
+	for _, in := range []string{"users", "users.", ".password", "a.b.c.d", "a..c"} {
+		if _, _, err := ParseColumnRef(in); err == nil {
+			t.Fatalf("%s: expected error", in)
+		}
+	}
+}
+
+func TestScheduleListUnmarshal(t *testing.T) {
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(`schedule: "08:00"`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Schedule) != 1 || cfg.Schedule[0] != "08:00" {
+		t.Fatalf("scalar: %v", cfg.Schedule)
+	}
+	if err := yaml.Unmarshal([]byte("schedule:\n  - \"0 * * * *\"\n  - \"@every 6h\"\n"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Schedule) != 2 || cfg.Schedule[1] != "@every 6h" {
+		t.Fatalf("list: %v", cfg.Schedule)
+	}
+}
+
+func TestResolveJobs(t *testing.T) {
+	var cfg Config
+	err := yaml.Unmarshal([]byte(`
+databases:
+  - name: hot
+    delivery: save
+  - name: cold
+files:
+  - ./a.db
+directories:
+  - ./uploads
+schedule: "08:00"
+jobs:
+  - name: hourly
+    schedule: "0 * * * *"
+    databases: [hot]
+    delivery: send
+  - name: nightly
+    databases: [cold]
+    directories: [./uploads]
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalizeConfig(&cfg)
+
+	jobs, err := cfg.ResolveJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("jobs: %d", len(jobs))
+	}
+	hourly, nightly := jobs[0], jobs[1]
+	if hourly.JobName != "hourly" || len(hourly.Databases) != 1 || hourly.Databases[0].Delivery != DeliverySend {
+		t.Fatalf("hourly: %+v", hourly.Databases)
+	}
+	if hourly.Schedule[0] != "0 * * * *" || nightly.Schedule[0] != "08:00" {
+		t.Fatalf("schedules: %v %v", hourly.Schedule, nightly.Schedule)
+	}
+	if len(nightly.Files) != 0 || len(nightly.Directories) != 1 || nightly.Databases[0].DBName != "cold" {
+		t.Fatalf("nightly: %+v", nightly)
+	}
+	if cfg.Databases[0].Delivery != DeliverySave {
+		t.Fatal("job delivery override leaked into base config")
+	}
+
+	cfg.Jobs = []JobConfig{{Name: "x", Databases: []string{"missing"}}}
+	if _, err := cfg.ResolveJobs(); err == nil {
+		t.Fatal("expected error for unknown database")
+	}
+}
+
+func TestSelectJobs(t *testing.T) {
+	base := &Config{
+		Databases: []DumpConfig{{DBName: "app", Delivery: DeliverySend}, {DBName: "crm", Delivery: DeliverySend}},
+		Files:     AssetList{{Path: "settings.json", Delivery: DeliverySend}},
+		Jobs: []JobConfig{
+			{Name: "nightly", Databases: []string{"app", "crm"}, Files: []string{"settings.json"}},
+			{Name: "hourly", Databases: []string{"app"}},
+		},
+	}
+	jobs, err := base.ResolveJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := SelectJobs(jobs, Selection{Databases: []string{"crm"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].JobName != "nightly" || len(got[0].Databases) != 1 || got[0].Databases[0].DBName != "crm" || len(got[0].Files) != 0 || !got[0].Partial {
+		t.Fatalf("select crm: %+v", got)
+	}
+
+	got, err = SelectJobs(jobs, Selection{Jobs: []string{"hourly"}})
+	if err != nil || len(got) != 1 || got[0].JobName != "hourly" || got[0].Partial {
+		t.Fatalf("select job: %v %+v", err, got)
+	}
+	if _, err := SelectJobs(jobs, Selection{Jobs: []string{"hourly"}, Files: []string{"settings.json"}}); err == nil {
+		t.Fatal("expected error for file outside selected job")
+	}
+
+	got[0].DisableDelivery()
+	if got[0].Databases[0].Delivery != DeliverySave || !got[0].NoDelivery {
+		t.Fatalf("delivery not disabled: %+v", got[0])
+	}
+	if jobs[0].Databases[0].Delivery != DeliverySend {
+		t.Fatal("DisableDelivery changed another job")
+	}
+}
+
+func TestReadFileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("123:abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yml")
+	err := os.WriteFile(path, []byte(`
+databases:
+  - host: ${DB_HOST:-localhost}
+    port: 5432
+    user: backup
+    password: "${DB_PASSWORD}"
+    name: app
+    jobs: ${DB_JOBS}
+    exclude: ["items.price_$${x}"]
+telegram:
+  token_file: `+tokenFile+`
+  chat_id: 1
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_PASSWORD", "null")
+	t.Setenv("DB_JOBS", "4")
+	t.Setenv("TGDUMP_TELEGRAM_CHAT_ID", "-1001")
+	t.Setenv("TGDUMP_DATABASES_0_DELIVERY", "save")
+	t.Setenv("TGDUMP_DUMP_DIR", "/backups")
+
+	cfg, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := cfg.Databases[0]
+	if db.Host != "localhost" || db.Password != "null" || db.Jobs != 4 || db.Exclude[0] != "items.price_${x}" || db.Delivery != DeliverySave {
+		t.Fatalf("database: %+v", db)
+	}
+	if cfg.Telegram.Token != "123:abc" || cfg.Telegram.ChatID != "-1001" || cfg.DumpDir != "/backups" {
+		t.Fatalf("config: %+v", cfg)
+	}
+
+	t.Setenv("DB_PASSWORD", "")
+	if _, err := ReadFile(path); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD") {
+		t.Fatalf("missing variable: %v", err)
+	}
+}
+
+func TestReadFileEnvOnly(t *testing.T) {
+	t.Setenv("TGDUMP_DATABASES_0_NAME", "app")
+	t.Setenv("TGDUMP_DATABASES_0_HOST", "db")
+	t.Setenv("TGDUMP_FILES_0", "./main.db")
+	t.Setenv("TGDUMP_SCHEDULE", "[08:00, 0 */6 * * *]")
+	t.Setenv("TGDUMP_PARALLEL_DUMPS", "2")
+
+	cfg, err := ReadFile(filepath.Join(t.TempDir(), "missing.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Databases) != 1 || cfg.Databases[0].Host != "db" || cfg.Databases[0].Delivery != DeliverySend {
+		t.Fatalf("databases: %+v", cfg.Databases)
+	}
+	if len(cfg.Files) != 1 || cfg.Files[0].Path != "./main.db" || len(cfg.Schedule) != 2 || cfg.Parallel.Dumps != 2 {
+		t.Fatalf("config: %+v", cfg)
+	}
+
+	t.Setenv("TGDUMP_TELEGRAM_TOKN", "x")
+	if _, err := ReadFile("missing.yml"); err == nil || !strings.Contains(err.Error(), "TGDUMP_TELEGRAM_TOKN") {
+		t.Fatalf("unknown key: %v", err)
+	}
+}
+
+func TestReadFileValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(`databases:
+  - name: app
+    port: 54x2
+    delivery: sned
+    exclude: [users]
+    pasword: secret
+  - name: app
+    password_file: /nonexistent/secret
+telegram:
+  chat_id: "@channel"
+  max_file_mb: lots
+schedule: "25:00"
+retention:
+  daily: -1
+jobs:
+  - name: bad name
+    databases: [app, missing]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ReadFile(path)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ожидалась ValidationError, получено %v", err)
+	}
+	want := []string{
+		"строка 3: databases[0].port",
+		"строка 4: databases[0].delivery",
+		"строка 5: databases[0].exclude[0]",
+		"строка 6: databases[0].pasword",
+		"строка 7: databases[1].name",
+		"строка 8: databases[1].password_file",
+		"строка 10: telegram.chat_id",
+		"строка 11: telegram.max_file_mb: неверный тип значения",
+		"строка 12: schedule[0]",
+		"строка 14: retention.daily",
+		"строка 16: jobs[0].name",
+		"строка 17: jobs[0].databases[1]",
+	}
+	if len(verr.Errors) != len(want) {
+		t.Fatalf("ошибки: %v", err)
+	}
+	for i, prefix := range want {
+		if got := verr.Errors[i].Error(); !strings.HasPrefix(got, prefix) {
+			t.Errorf("ошибка %d: %q, ожидается %q", i, got, prefix)
+		}
+	}
+}
@@ -36,6 +36,11 @@ func NormalizeDelivery(d Delivery) Delivery {
 type AssetEntry struct {
 	Path     string   `yaml:"path"`
 	Delivery Delivery `yaml:"delivery"`
+
+	// Только для каталогов: копировать изменённые с прошлого запуска файлы,
+	// полная копия раз в FullEvery запусков.
+	Incremental bool `yaml:"incremental"`
+	FullEvery   int  `yaml:"full_every"`
 }
 
 type AssetList []AssetEntry
@@ -57,7 +62,6 @@ func (l *AssetList) UnmarshalYAML(node *yaml.Node) error {
 			if err := item.Decode(&entry); err != nil {
 				return fmt.Errorf("files/directories[%d]: %w", i, err)
 			}
-			entry.Delivery = NormalizeDelivery(entry.Delivery)
 			if entry.Path == "" {
 				return fmt.Errorf("files/directories[%d]: path is required", i)
 			}
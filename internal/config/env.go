@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix — префикс переменных окружения, переопределяющих конфигурацию:
+// TGDUMP_TELEGRAM_TOKEN, TGDUMP_DATABASES_0_HOST, TGDUMP_SCHEDULE='[08:00, 20:00]'.
+const EnvPrefix = "TGDUMP_"
+
+// envReserved — переменные с префиксом, которые не относятся к конфигурации.
+var envReserved = map[string]bool{
+	"TGDUMP_PASSPHRASE": true, // пароль для команды decrypt
+}
+
+// envOverrides возвращает переменные TGDUMP_* в порядке имён.
+func envOverrides(environ []string) [][2]string {
+	var out [][2]string
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || envReserved[name] {
+			continue
+		}
+		out = append(out, [2]string{name, value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
+
+// applyEnvOverrides записывает значения TGDUMP_* поверх конфигурации. Путь
+// строится из ключей YAML через "_", элементы списков — по индексу.
+func applyEnvOverrides(cfg *Config, environ []string) error {
+	for _, kv := range envOverrides(environ) {
+		path := strings.ToLower(strings.TrimPrefix(kv[0], EnvPrefix))
+		if err := setPath(reflect.ValueOf(cfg).Elem(), path, kv[1]); err != nil {
+			return fmt.Errorf("%s: %w", kv[0], err)
+		}
+	}
+	return nil
+}
+
+func setPath(v reflect.Value, path, value string) error {
+	if path == "" {
+		return setValue(v, value)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return setStructPath(v, path, value)
+	case reflect.Slice:
+		idx, rest, _ := strings.Cut(path, "_")
+		i, err := strconv.Atoi(idx)
+		if err != nil || i < 0 {
+			return fmt.Errorf("ожидается индекс элемента списка, получено %q", idx)
+		}
+		if i >= v.Len() {
+			grown := reflect.MakeSlice(v.Type(), i+1, i+1)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+		if rest == "" {
+			return setElement(v, i, value)
+		}
+		return setPath(v.Index(i), rest, value)
+	default:
+		return fmt.Errorf("поле %q не поддерживает вложенные ключи", path)
+	}
+}
+
+// setStructPath ищет поле по тегу yaml; длинные теги проверяются первыми,
+// чтобы dump_dir не разбирался как dump + dir.
+func setStructPath(v reflect.Value, path, value string) error {
+	type field struct {
+		tag   string
+		index int
+	}
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		fields = append(fields, field{tag, i})
+	}
+	sort.Slice(fields, func(i, j int) bool { return len(fields[i].tag) > len(fields[j].tag) })
+
+	for _, f := range fields {
+		if path == f.tag {
+			return setPath(v.Field(f.index), "", value)
+		}
+		if rest, ok := strings.CutPrefix(path, f.tag+"_"); ok {
+			return setPath(v.Field(f.index), rest, value)
+		}
+	}
+	return fmt.Errorf("неизвестный параметр %q", path)
+}
+
+// setValue записывает значение: строки как есть, остальное разбирается как YAML.
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(value)
+		return nil
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+		return err
+	}
+	if len(node.Content) == 0 {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	return node.Content[0].Decode(v.Addr().Interface())
+}
+
+// setElement записывает элемент списка целиком через разбор списка из одного
+// элемента, чтобы сработал UnmarshalYAML списка (например, files: [./a]).
+func setElement(list reflect.Value, i int, value string) error {
+	if list.Type().Elem().Kind() == reflect.String {
+		list.Index(i).SetString(value)
+		return nil
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &node); err != nil {
+		return err
+	}
+	if len(node.Content) == 0 {
+		return fmt.Errorf("пустое значение элемента")
+	}
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{node.Content[0]}}
+	one := reflect.New(list.Type())
+	if err := seq.Decode(one.Interface()); err != nil {
+		return err
+	}
+	list.Index(i).Set(one.Elem().Index(0))
+	return nil
+}
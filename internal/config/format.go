@@ -0,0 +1,46 @@
+package config
+
+type DumpFormat string
+
+const (
+	FormatPlain     DumpFormat = "plain"     // SQL-скрипт, pg_dump -F p
+	FormatCustom    DumpFormat = "custom"    // сжатый архив pg_dump -F c
+	FormatDirectory DumpFormat = "directory" // каталог pg_dump -F d, поддерживает --jobs
+	FormatTar       DumpFormat = "tar"       // tar-архив pg_dump -F t
+)
+
+// PgDumpFlag возвращает значение для ключа pg_dump -F.
+func (f DumpFormat) PgDumpFlag() string {
+	switch NormalizeFormat(f) {
+	case FormatCustom:
+		return "c"
+	case FormatDirectory:
+		return "d"
+	case FormatTar:
+		return "t"
+	default:
+		return "p"
+	}
+}
+
+// IsPlain сообщает, что дамп восстанавливается через psql, а не pg_restore.
+func (f DumpFormat) IsPlain() bool {
+	return NormalizeFormat(f) == FormatPlain
+}
+
+func NormalizeFormat(f DumpFormat) DumpFormat {
+	switch f {
+	case FormatPlain, FormatCustom, FormatDirectory, FormatTar:
+		return f
+	case "p":
+		return FormatPlain
+	case "c":
+		return FormatCustom
+	case "d":
+		return FormatDirectory
+	case "t":
+		return FormatTar
+	default:
+		return FormatPlain
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// JobConfig — именованное задание со своим расписанием. Базы указываются по
+// имени из databases, файлы и каталоги — по path из files и directories.
+type JobConfig struct {
+	Name        string       `yaml:"name"`
+	Schedule    ScheduleList `yaml:"schedule"`
+	Databases   []string     `yaml:"databases"`
+	Files       []string     `yaml:"files"`
+	Directories []string     `yaml:"directories"`
+	Delivery    Delivery     `yaml:"delivery"` // если задано, заменяет delivery всех элементов
+}
+
+var jobNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ResolveJobs возвращает конфигурацию каждого задания: только его элементы и
+// его расписание. Без jobs вся конфигурация — одно задание без имени.
+func (c *Config) ResolveJobs() ([]*Config, error) {
+	if len(c.Jobs) == 0 {
+		job := *c
+		return []*Config{&job}, nil
+	}
+
+	seen := make(map[string]struct{}, len(c.Jobs))
+	jobs := make([]*Config, 0, len(c.Jobs))
+	for _, jc := range c.Jobs {
+		if !jobNamePattern.MatchString(jc.Name) {
+			return nil, fmt.Errorf("jobs: некорректное имя задания %q (допустимы буквы, цифры, _ и -)", jc.Name)
+		}
+		if _, dup := seen[jc.Name]; dup {
+			return nil, fmt.Errorf("jobs: повторяющееся имя задания %q", jc.Name)
+		}
+		seen[jc.Name] = struct{}{}
+
+		job, err := c.resolveJob(jc)
+		if err != nil {
+			return nil, fmt.Errorf("jobs[%s]: %w", jc.Name, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (c *Config) resolveJob(jc JobConfig) (*Config, error) {
+	job := *c
+	job.Jobs = nil
+	job.JobName = jc.Name
+	job.Databases = nil
+	job.Files = nil
+	job.Directories = nil
+	if len(jc.Schedule) > 0 {
+		job.Schedule = jc.Schedule
+	}
+
+	for _, name := range jc.Databases {
+		db, ok := findDatabase(c.Databases, name)
+		if !ok {
+			return nil, fmt.Errorf("база %q не описана в databases", name)
+		}
+		if jc.Delivery != "" {
+			db.Delivery = NormalizeDelivery(jc.Delivery)
+		}
+		job.Databases = append(job.Databases, db)
+	}
+	for _, path := range jc.Files {
+		entry, ok := findAsset(c.Files, path)
+		if !ok {
+			return nil, fmt.Errorf("файл %q не описан в files", path)
+		}
+		if jc.Delivery != "" {
+			entry.Delivery = NormalizeDelivery(jc.Delivery)
+		}
+		job.Files = append(job.Files, entry)
+	}
+	for _, path := range jc.Directories {
+		entry, ok := findAsset(c.Directories, path)
+		if !ok {
+			return nil, fmt.Errorf("каталог %q не описан в directories", path)
+		}
+		if jc.Delivery != "" {
+			entry.Delivery = NormalizeDelivery(jc.Delivery)
+		}
+		job.Directories = append(job.Directories, entry)
+	}
+	return &job, nil
+}
+
+func findDatabase(dbs []DumpConfig, name string) (DumpConfig, bool) {
+	for _, db := range dbs {
+		if db.DBName == name {
+			return db, true
+		}
+	}
+	return DumpConfig{}, false
+}
+
+func findAsset(list AssetList, path string) (AssetEntry, bool) {
+	for _, entry := range list {
+		if entry.Path == path {
+			return entry, true
+		}
+	}
+	return AssetEntry{}, false
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type MaskKind string
+
+const (
+	MaskNull      MaskKind = "null"       // NULL вместо значения
+	MaskHash      MaskKind = "hash"       // md5 от текстового значения
+	MaskFixed     MaskKind = "fixed"      // fixed:<значение>
+	MaskFakeEmail MaskKind = "fake_email" // user_<md5>@example.com, уникален для уникальных значений
+	MaskTruncate  MaskKind = "truncate"   // truncate[:N], первые N символов (по умолчанию пустая строка)
+)
+
+type MaskRule struct {
+	Kind   MaskKind
+	Value  string
+	Length int
+}
+
+func (r MaskRule) String() string {
+	switch r.Kind {
+	case MaskFixed:
+		return string(r.Kind) + ":" + r.Value
+	case MaskTruncate:
+		if r.Length > 0 {
+			return string(r.Kind) + ":" + strconv.Itoa(r.Length)
+		}
+	}
+	return string(r.Kind)
+}
+
+// ParseMaskRule разбирает правило маскирования вида "hash", "fixed:value" или "truncate:8".
+func ParseMaskRule(s string) (MaskRule, error) {
+	kind, arg, hasArg := strings.Cut(strings.TrimSpace(s), ":")
+	rule := MaskRule{Kind: MaskKind(kind)}
+	switch rule.Kind {
+	case MaskNull, MaskHash, MaskFakeEmail:
+		if hasArg {
+			return MaskRule{}, fmt.Errorf("правило %s не принимает аргумент", kind)
+		}
+	case MaskFixed:
+		if !hasArg {
+			return MaskRule{}, fmt.Errorf("правило fixed требует значение: fixed:<value>")
+		}
+		rule.Value = arg
+	case MaskTruncate:
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return MaskRule{}, fmt.Errorf("некорректная длина truncate: %q", arg)
+			}
+			rule.Length = n
+		}
+	default:
+		return MaskRule{}, fmt.Errorf("неизвестное правило маскирования: %q", s)
+	}
+	return rule, nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScheduleList — одно расписание строкой или список расписаний.
+type ScheduleList []string
+
+func (l *ScheduleList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*l = ScheduleList{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+		*l = items
+		return nil
+	default:
+		return fmt.Errorf("schedule: ожидается строка или список строк")
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envRef — ссылка ${VAR} или ${VAR:-значение по умолчанию}; $${...} оставляется
+// как есть без первого $.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// expandEnv подставляет переменные окружения в значения YAML (не в ключи).
+func expandEnv(node *yaml.Node, lookup func(string) (string, bool)) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := expandEnv(child, lookup); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandEnv(node.Content[i], lookup); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		value, err := expandString(node.Value, lookup)
+		if err != nil {
+			return fmt.Errorf("строка %d: %w", node.Line, err)
+		}
+		if value != node.Value {
+			node.Value = value
+			if node.Style == 0 {
+				// Тип значения без кавычек определяется после подстановки.
+				node.Tag = ""
+			}
+		}
+	}
+	return nil
+}
+
+func expandString(s string, lookup func(string) (string, bool)) (string, error) {
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		m := envRef.FindStringSubmatch(ref)
+		if value, ok := lookup(m[1]); ok && value != "" {
+			return value
+		}
+		if m[2] != "" {
+			return strings.TrimPrefix(m[2], ":-")
+		}
+		missing = append(missing, m[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("не задана переменная окружения %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения файла секрета: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveSecret читает значение из file, если он задан. Одновременно
+// значение и файл задавать нельзя.
+func resolveSecret(value *string, file string) error {
+	if file == "" {
+		return nil
+	}
+	if *value != "" {
+		return fmt.Errorf("нельзя одновременно указывать значение и файл")
+	}
+	secret, err := readSecretFile(file)
+	if err != nil {
+		return err
+	}
+	*value = secret
+	return nil
+}
+
+// resolveSecrets подставляет пароли и токен из *_file; ошибки добавляются в v.
+func (c *Config) resolveSecrets(v *validator) {
+	secret := func(value *string, file, field string) {
+		if err := resolveSecret(value, file); err != nil {
+			v.addf(field, "%v", err)
+		}
+	}
+	for i := range c.Databases {
+		db := &c.Databases[i]
+		secret(&db.Password, db.PasswordFile, fmt.Sprintf("databases[%d].password_file", i))
+	}
+	secret(&c.Verify.Password, c.Verify.PasswordFile, "verify.password_file")
+	secret(&c.Telegram.Token, c.Telegram.TokenFile, "telegram.token_file")
+	secret(&c.Encryption.Passphrase, c.Encryption.PassphraseFile, "encryption.passphrase_file")
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Selection — задания и элементы, выбранные в командной строке. Если ни одна
+// база, файл или каталог не выбраны, задания остаются целиком.
+type Selection struct {
+	Jobs        []string
+	Databases   []string
+	Files       []string
+	Directories []string
+}
+
+func (s Selection) itemsSelected() bool {
+	return len(s.Databases) > 0 || len(s.Files) > 0 || len(s.Directories) > 0
+}
+
+// SelectJobs оставляет в заданиях только выбранные элементы и пропускает
+// задания, в которых ничего не осталось. Неизвестное имя — ошибка.
+func SelectJobs(jobs []*Config, sel Selection) ([]*Config, error) {
+	for _, name := range sel.Jobs {
+		if !slices.ContainsFunc(jobs, func(j *Config) bool { return j.JobName == name }) {
+			return nil, fmt.Errorf("задание %q не найдено", name)
+		}
+	}
+
+	found := make(map[string]bool)
+	var out []*Config
+	for _, job := range jobs {
+		if len(sel.Jobs) > 0 && !slices.Contains(sel.Jobs, job.JobName) {
+			continue
+		}
+		if !sel.itemsSelected() {
+			out = append(out, job)
+			continue
+		}
+
+		selected := *job
+		selected.Partial = true
+		selected.Databases = nil
+		selected.Files = nil
+		selected.Directories = nil
+		for _, db := range job.Databases {
+			if slices.Contains(sel.Databases, db.DBName) {
+				selected.Databases = append(selected.Databases, db)
+				found["db:"+db.DBName] = true
+			}
+		}
+		for _, f := range job.Files {
+			if slices.Contains(sel.Files, f.Path) {
+				selected.Files = append(selected.Files, f)
+				found["file:"+f.Path] = true
+			}
+		}
+		for _, d := range job.Directories {
+			if slices.Contains(sel.Directories, d.Path) {
+				selected.Directories = append(selected.Directories, d)
+				found["dir:"+d.Path] = true
+			}
+		}
+		if len(selected.Databases)+len(selected.Files)+len(selected.Directories) > 0 {
+			out = append(out, &selected)
+		}
+	}
+
+	for _, name := range sel.Databases {
+		if !found["db:"+name] {
+			return nil, fmt.Errorf("база %q не найдена в выбранных заданиях", name)
+		}
+	}
+	for _, path := range sel.Files {
+		if !found["file:"+path] {
+			return nil, fmt.Errorf("файл %q не найден в выбранных заданиях", path)
+		}
+	}
+	for _, path := range sel.Directories {
+		if !found["dir:"+path] {
+			return nil, fmt.Errorf("каталог %q не найден в выбранных заданиях", path)
+		}
+	}
+	return out, nil
+}
+
+// DisableDelivery отключает Telegram: архив только сохраняется локально,
+// отчёт и уведомления пишутся в лог.
+func (c *Config) DisableDelivery() {
+	c.NoDelivery = true
+	c.Databases = slices.Clone(c.Databases)
+	for i := range c.Databases {
+		c.Databases[i].Delivery = DeliverySave
+	}
+	c.Files = disableAssetDelivery(c.Files)
+	c.Directories = disableAssetDelivery(c.Directories)
+}
+
+func disableAssetDelivery(list AssetList) AssetList {
+	out := make(AssetList, len(list))
+	for i, entry := range list {
+		entry.Delivery = DeliverySave
+		out[i] = entry
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultSchema = "public"
+
+// TableRef — таблица с явной схемой.
+type TableRef struct {
+	Schema string
+	Table  string
+}
+
+func (t TableRef) String() string {
+	return t.Schema + "." + t.Table
+}
+
+// ParseTableRef разбирает "table" или "schema.table"; без схемы подставляется public.
+func ParseTableRef(s string) (TableRef, error) {
+	parts := strings.Split(s, ".")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return TableRef{Schema: defaultSchema, Table: parts[0]}, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return TableRef{Schema: parts[0], Table: parts[1]}, nil
+	default:
+		return TableRef{}, fmt.Errorf("ожидается table или schema.table: %q", s)
+	}
+}
+
+// ParseColumnRef разбирает "table.column" или "schema.table.column".
+func ParseColumnRef(s string) (TableRef, string, error) {
+	i := strings.LastIndex(s, ".")
+	if i <= 0 || i == len(s)-1 {
+		return TableRef{}, "", fmt.Errorf("ожидается table.column или schema.table.column: %q", s)
+	}
+	table, err := ParseTableRef(s[:i])
+	if err != nil {
+		return TableRef{}, "", fmt.Errorf("ожидается table.column или schema.table.column: %q", s)
+	}
+	return table, s[i+1:], nil
+}
+
+// SchemaSelected сообщает, попадает ли схема в дамп с учётом schemas и exclude_schemas.
+func (c DumpConfig) SchemaSelected(schema string) bool {
+	for _, s := range c.ExcludeSchemas {
+		if s == schema {
+			return false
+		}
+	}
+	if len(c.Schemas) == 0 {
+		return true
+	}
+	for _, s := range c.Schemas {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
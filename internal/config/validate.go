@@ -0,0 +1,358 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"tgdump/internal/scheduler"
+)
+
+// FieldError — ошибка в одном параметре конфигурации. Line — строка в YAML,
+// 0 если значение задано переменной окружения или не задано вовсе.
+type FieldError struct {
+	Line  int
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("строка %d: %v", e.Line, e.Err)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("строка %d: %s: %v", e.Line, e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError собирает все ошибки, найденные в конфигурации.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ошибки в конфигурации (%d):", len(e.Errors))
+	for _, fe := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+// validator накапливает ошибки и знает строки YAML, на которых заданы значения.
+type validator struct {
+	lines map[string]int
+	errs  []*FieldError
+}
+
+func newValidator() *validator {
+	return &validator{lines: make(map[string]int)}
+}
+
+func (v *validator) addf(field, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Line: lineOf(v.lines, field), Field: field, Err: fmt.Errorf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errs, func(i, j int) bool { return v.errs[i].Line < v.errs[j].Line })
+	return &ValidationError{Errors: v.errs}
+}
+
+// lineOf возвращает строку параметра field или ближайшего родителя, если
+// сам параметр в YAML не задан.
+func lineOf(lines map[string]int, field string) int {
+	for field != "" {
+		if line, ok := lines[field]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+	return 0
+}
+
+// index запоминает строки всех значений в node и сообщает о ключах, которых
+// нет в типе t.
+func (v *validator) index(node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			v.index(child, t, path)
+		}
+		return
+	case yaml.AliasNode:
+		v.index(node.Alias, t, path)
+		return
+	}
+	if path != "" {
+		v.lines[path] = node.Line
+	}
+
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field := joinPath(path, key.Value)
+			ft, ok := fields[key.Value]
+			if !ok {
+				v.errs = append(v.errs, &FieldError{Line: key.Line, Field: field, Err: fmt.Errorf("неизвестный параметр")})
+				continue
+			}
+			v.index(value, ft, field)
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.index(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			v.index(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// typeErrors добавляет ошибки типов из yaml с параметром, заданным на той же
+// строке. Вызывается после index.
+func (v *validator) typeErrors(err *yaml.TypeError) {
+	for _, msg := range err.Errors {
+		var line int
+		if _, scanErr := fmt.Sscanf(msg, "line %d:", &line); scanErr == nil {
+			_, msg, _ = strings.Cut(msg, ": ")
+		}
+		// Самый длинный путь на строке — значение, а не содержащий его блок.
+		var field string
+		for path, l := range v.lines {
+			if l == line && (len(path) > len(field) || len(path) == len(field) && path < field) {
+				field = path
+			}
+		}
+		v.errs = append(v.errs, &FieldError{Line: line, Field: field, Err: fmt.Errorf("неверный тип значения: %s", msg)})
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// check проверяет значения конфигурации до нормализации, пока неизвестные
+// значения ещё не заменены значениями по умолчанию.
+func (v *validator) check(c *Config) {
+	// По имени базы называются файлы дампа и ссылки из jobs, поэтому оно
+	// должно быть уникальным даже для разных серверов.
+	names := make(map[string]int, len(c.Databases))
+	for i, db := range c.Databases {
+		field := fmt.Sprintf("databases[%d]", i)
+		if db.DBName == "" {
+			v.addf(field+".name", "не задано имя базы")
+		} else if first, dup := names[db.DBName]; dup {
+			v.addf(field+".name", "база %q уже описана в databases[%d]", db.DBName, first)
+		} else {
+			names[db.DBName] = i
+		}
+		v.port(field+".port", db.Port)
+		v.delivery(field+".delivery", db.Delivery)
+		if db.Format != "" && NormalizeFormat(db.Format) == FormatPlain && db.Format != FormatPlain && db.Format != "p" {
+			v.addf(field+".format", "неизвестный формат %q (допустимо plain, custom, directory или tar)", db.Format)
+		}
+		for j, item := range db.Exclude {
+			if _, _, err := ParseColumnRef(item); err != nil {
+				v.addf(fmt.Sprintf("%s.exclude[%d]", field, j), "%v", err)
+			}
+		}
+		for item, rule := range db.Mask {
+			if _, _, err := ParseColumnRef(item); err != nil {
+				v.addf(field+".mask."+item, "%v", err)
+			} else if _, err := ParseMaskRule(rule); err != nil {
+				v.addf(field+".mask."+item, "%v", err)
+			}
+		}
+		for item := range db.Filters {
+			if _, err := ParseTableRef(item); err != nil {
+				v.addf(field+".filters."+item, "%v", err)
+			}
+		}
+		v.nonNegative(field+".jobs", db.Jobs)
+	}
+	for i, entry := range c.Files {
+		v.delivery(fmt.Sprintf("files[%d].delivery", i), entry.Delivery)
+	}
+	for i, entry := range c.Directories {
+		v.delivery(fmt.Sprintf("directories[%d].delivery", i), entry.Delivery)
+		v.nonNegative(fmt.Sprintf("directories[%d].full_every", i), entry.FullEvery)
+	}
+
+	v.chatID("telegram.chat_id", c.Telegram.ChatID)
+	v.chatID("telegram.alert_chat_id", c.Telegram.AlertChat)
+	v.nonNegative("telegram.max_file_mb", c.Telegram.MaxFileMB)
+	v.port("verify.port", c.Verify.Port)
+
+	v.nonNegative("parallel.dumps", c.Parallel.Dumps)
+	v.nonNegative("parallel.per_host", c.Parallel.PerHost)
+	v.nonNegative("retention.keep_last", c.Retention.KeepLast)
+	v.nonNegative("retention.daily", c.Retention.Daily)
+	v.nonNegative("retention.weekly", c.Retention.Weekly)
+	v.nonNegative("retention.monthly", c.Retention.Monthly)
+	v.nonNegative("retention.max_total_mb", c.Retention.MaxTotalMB)
+	if c.ShutdownGrace < 0 {
+		v.addf("shutdown_grace", "отрицательное значение %s", c.ShutdownGrace)
+	}
+
+	v.schedule("schedule", c.Schedule)
+	v.jobs(c)
+
+	switch c.RunOnStart {
+	case "", RunOnStartMissed, RunOnStartAlways, RunOnStartNever:
+	default:
+		v.addf("run_on_start", "неизвестное значение %q (допустимо missed, always или never)", c.RunOnStart)
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			v.addf("timezone", "некорректный часовой пояс %q: %v", c.Timezone, err)
+		}
+	}
+}
+
+// jobs проверяет имена заданий и ссылки на базы, файлы и каталоги.
+func (v *validator) jobs(c *Config) {
+	names := make(map[string]int, len(c.Jobs))
+	for i, jc := range c.Jobs {
+		field := fmt.Sprintf("jobs[%d]", i)
+		if !jobNamePattern.MatchString(jc.Name) {
+			v.addf(field+".name", "некорректное имя задания %q (допустимы буквы, цифры, _ и -)", jc.Name)
+		} else if first, dup := names[jc.Name]; dup {
+			v.addf(field+".name", "задание %q уже описано в jobs[%d]", jc.Name, first)
+		} else {
+			names[jc.Name] = i
+		}
+		v.schedule(field+".schedule", jc.Schedule)
+		v.delivery(field+".delivery", jc.Delivery)
+		for j, name := range jc.Databases {
+			if _, ok := findDatabase(c.Databases, name); !ok {
+				v.addf(fmt.Sprintf("%s.databases[%d]", field, j), "база %q не описана в databases", name)
+			}
+		}
+		for j, path := range jc.Files {
+			if _, ok := findAsset(c.Files, path); !ok {
+				v.addf(fmt.Sprintf("%s.files[%d]", field, j), "файл %q не описан в files", path)
+			}
+		}
+		for j, path := range jc.Directories {
+			if _, ok := findAsset(c.Directories, path); !ok {
+				v.addf(fmt.Sprintf("%s.directories[%d]", field, j), "каталог %q не описан в directories", path)
+			}
+		}
+	}
+}
+
+func (v *validator) nonNegative(field string, n int) {
+	if n < 0 {
+		v.addf(field, "отрицательное значение %d", n)
+	}
+}
+
+func (v *validator) delivery(field string, d Delivery) {
+	if d != "" && NormalizeDelivery(d) != d {
+		v.addf(field, "неизвестное значение %q (допустимо save или send)", d)
+	}
+}
+
+func (v *validator) port(field, port string) {
+	if port == "" {
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		v.addf(field, "некорректный порт %q", port)
+	}
+}
+
+func (v *validator) chatID(field, chat string) {
+	if chat == "" {
+		return
+	}
+	if _, err := strconv.ParseInt(chat, 10, 64); err != nil {
+		v.addf(field, "ожидается числовой идентификатор чата, получено %q", chat)
+	}
+}
+
+func (v *validator) schedule(field string, specs ScheduleList) {
+	for i, spec := range specs {
+		if _, err := scheduler.ParseSpec(spec); err != nil {
+			v.addf(fmt.Sprintf("%s[%d]", field, i), "некорректное расписание %q: %v", spec, err)
+		}
+	}
+}
+
+// CheckPaths проверяет, что файлы и каталоги из files и directories
+// существуют в files_dir. Возвращает *ValidationError со всеми найденными
+// проблемами.
+func (c *Config) CheckPaths() error {
+	v := &validator{lines: c.lines}
+	for i, entry := range c.Files {
+		field := fmt.Sprintf("files[%d]", i)
+		info, err := os.Stat(filepath.Join(c.FilesDir, entry.Path))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			v.addf(field, "файл %s не найден в %s", entry.Path, c.FilesDir)
+		case err != nil:
+			v.addf(field, "%v", err)
+		case info.IsDir():
+			v.addf(field, "%s — каталог, ожидается файл", entry.Path)
+		}
+	}
+	for i, entry := range c.Directories {
+		field := fmt.Sprintf("directories[%d]", i)
+		info, err := os.Stat(filepath.Join(c.FilesDir, entry.Path))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			v.addf(field, "каталог %s не найден в %s", entry.Path, c.FilesDir)
+		case err != nil:
+			v.addf(field, "%v", err)
+		case !info.IsDir():
+			v.addf(field, "%s — не каталог", entry.Path)
+		}
+	}
+	return v.err()
+}
@@ -0,0 +1,150 @@
+package crypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+
+	"tgdump/internal/config"
+)
+
+// Ext — расширение зашифрованных архивов.
+const Ext = ".age"
+
+func recipients(cfg config.EncryptionConfig) ([]age.Recipient, error) {
+	if cfg.Passphrase != "" {
+		if len(cfg.Recipients) > 0 {
+			return nil, fmt.Errorf("encryption: нельзя одновременно указывать passphrase и recipients")
+		}
+		r, err := age.NewScryptRecipient(cfg.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: %w", err)
+		}
+		return []age.Recipient{r}, nil
+	}
+
+	var out []age.Recipient
+	for _, key := range cfg.Recipients {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("encryption: некорректный ключ получателя %q: %w", key, err)
+		}
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("encryption: не заданы recipients или passphrase")
+	}
+	return out, nil
+}
+
+// EncryptFile шифрует src в src+".age" и возвращает путь к результату.
+// Отмена ctx прерывает шифрование, недописанный файл удаляется.
+func EncryptFile(ctx context.Context, src string, cfg config.EncryptionConfig) (string, error) {
+	recs, err := recipients(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer in.Close()
+
+	dst := src + Ext
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания файла %s: %w", dst, err)
+	}
+	// Недописанный .age выглядел бы как готовый архив для list и retention.
+	if err := encrypt(out, contextReader{ctx, in}, recs); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return "", fmt.Errorf("ошибка шифрования %s: %w", src, err)
+	}
+	return dst, nil
+}
+
+func encrypt(out *os.File, in io.Reader, recs []age.Recipient) error {
+	w, err := age.Encrypt(out, recs...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// contextReader прерывает чтение после отмены ctx.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// DecryptFile расшифровывает src в dst ключами из identityFile или паролем.
+func DecryptFile(src, dst, identityFile, passphrase string) error {
+	var ids []age.Identity
+	if identityFile != "" {
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return fmt.Errorf("ошибка открытия файла ключей: %w", err)
+		}
+		defer f.Close()
+		parsed, err := age.ParseIdentities(f)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения ключей: %w", err)
+		}
+		ids = append(ids, parsed...)
+	}
+	if passphrase != "" {
+		id, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("не задан ключ или пароль для расшифровки")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла: %w", err)
+	}
+	defer in.Close()
+
+	r, err := age.Decrypt(in, ids...)
+	if err != nil {
+		return fmt.Errorf("ошибка расшифровки: %w", err)
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return fmt.Errorf("ошибка расшифровки %s: %w", src, err)
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
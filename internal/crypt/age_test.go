@@ -0,0 +1,75 @@
+package crypt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+
+	"tgdump/internal/config"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.zip")
+	if err := os.WriteFile(src, []byte("payload"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(keyFile, []byte(id.String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := EncryptFile(context.Background(), src, config.EncryptionConfig{Recipients: []string{id.Recipient().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.zip")
+	if err := DecryptFile(enc, out, keyFile, ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "payload" {
+		t.Fatalf("decrypted: %q", data)
+	}
+	if err := DecryptFile(enc, out, "", "wrong"); err == nil {
+		t.Fatal("expected error with wrong passphrase")
+	}
+
+	data, err := os.ReadFile(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "truncated.zip.age")
+	if err := os.WriteFile(truncated, data[:len(data)-10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	partial := filepath.Join(dir, "partial.zip")
+	if err := DecryptFile(truncated, partial, keyFile, ""); err == nil {
+		t.Fatal("expected error for truncated archive")
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Fatalf("partial output left after failed decrypt: %v", err)
+	}
+
+	srcDir := filepath.Join(dir, "dir")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EncryptFile(context.Background(), srcDir, config.EncryptionConfig{Passphrase: "p"}); err == nil {
+		t.Fatal("expected error for directory")
+	}
+	if _, err := os.Stat(srcDir + Ext); !os.IsNotExist(err) {
+		t.Fatalf("partial output left after failed encrypt: %v", err)
+	}
+
+	if _, err := EncryptFile(context.Background(), src, config.EncryptionConfig{Passphrase: "p", Recipients: []string{id.Recipient().String()}}); err == nil {
+		t.Fatal("expected error for passphrase with recipients")
+	}
+}
@@ -0,0 +1,103 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"tgdump/internal/config"
+)
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// runner выполняет psql и pg_restore; в режиме dryRun только печатает команды.
+type runner struct {
+	dryRun   bool
+	password string // пусто — libpq ищет пароль в PGPASSFILE или ~/.pgpass
+	passFile string
+}
+
+func (r runner) psql(ctx context.Context, target config.DumpConfig, args ...string) error {
+	base := []string{
+		"-X", "-q",
+		"-v", "ON_ERROR_STOP=1",
+		"-h", target.Host,
+		"-p", target.Port,
+		"-U", target.User,
+		"-d", target.DBName,
+	}
+	return r.run(ctx, "psql", append(base, args...)...)
+}
+
+// maintenance выполняет запрос в служебной базе postgres на сервере target.
+func (r runner) maintenance(ctx context.Context, target config.DumpConfig, query string) error {
+	target.DBName = "postgres"
+	return r.psql(ctx, target, "-c", query)
+}
+
+func (r runner) pgRestore(ctx context.Context, target config.DumpConfig, args ...string) error {
+	base := []string{
+		"--exit-on-error",
+		"-h", target.Host,
+		"-p", target.Port,
+		"-U", target.User,
+		"-d", target.DBName,
+	}
+	return r.run(ctx, "pg_restore", append(base, args...)...)
+}
+
+func (r runner) run(ctx context.Context, name string, args ...string) error {
+	if r.dryRun {
+		log.Printf("[dry-run] %s %s", name, strings.Join(args, " "))
+		return nil
+	}
+	log.Printf("%s %s", name, strings.Join(args, " "))
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = os.Environ()
+	if r.password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+r.password)
+	}
+	if r.passFile != "" {
+		cmd.Env = append(cmd.Env, "PGPASSFILE="+r.passFile)
+	}
+	stopOnCancel(cmd)
+	// stderr виден в логе и сохраняется в ошибке для уведомления.
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	if err := cmd.Run(); err != nil {
+		return &CommandError{Name: name, Err: err, Stderr: stderr.String()}
+	}
+	return nil
+}
+
+// CommandError — ошибка внешней команды с её stderr.
+type CommandError struct {
+	Name   string
+	Err    error
+	Stderr string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("ошибка выполнения %s: %v", e.Name, e.Err)
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+const childStopTimeout = 10 * time.Second
+
+// stopOnCancel завершает процесс по SIGTERM при отмене контекста; если он не
+// вышел за childStopTimeout, процесс убивается.
+func stopOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = childStopTimeout
+}
@@ -0,0 +1,203 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"tgdump/internal/archive"
+	"tgdump/internal/config"
+)
+
+type Options struct {
+	Archive  string
+	Database string            // имя базы в архиве; пусто — базы не восстанавливаются
+	Target   config.DumpConfig // подключение и имя целевой базы
+	Files    bool              // вернуть файлы и каталоги в FilesDir
+	FilesDir string
+	DryRun   bool
+	Drop     bool // удалить и заново создать целевую базу
+	Jobs     int
+}
+
+// Contents форматирует список содержимого архива.
+func Contents(m archive.Manifest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Резервная копия: %s (tgdump %s)\n", m.Timestamp, m.Version)
+	b.WriteString("Базы:\n")
+	for _, db := range m.Databases {
+		fmt.Fprintf(&b, "  - %s (%s): %s\n", db.Name, db.Format, db.Path)
+	}
+	b.WriteString("Файлы:\n")
+	for _, f := range m.Files {
+		fmt.Fprintf(&b, "  - %s\n", f.Path)
+	}
+	b.WriteString("Каталоги:\n")
+	for _, d := range m.Directories {
+		if d.Incremental {
+			fmt.Fprintf(&b, "  - %s (инкремент, полная копия в архиве %s)\n", d.Path, d.Base)
+		} else {
+			fmt.Fprintf(&b, "  - %s\n", d.Path)
+		}
+	}
+	return b.String()
+}
+
+// Run восстанавливает выбранную базу и/или файлы из zip-архива tgdump.
+func Run(ctx context.Context, opts Options) error {
+	m, err := archive.ReadManifest(opts.Archive)
+	if err != nil {
+		return err
+	}
+	if opts.Database == "" && !opts.Files {
+		return fmt.Errorf("не выбрано, что восстанавливать: укажите базу или файлы")
+	}
+
+	if opts.Database != "" {
+		entry, ok := findDatabase(m, opts.Database)
+		if !ok {
+			return fmt.Errorf("база %s не найдена в архиве", opts.Database)
+		}
+		if err := restoreFromArchive(ctx, opts, entry); err != nil {
+			return fmt.Errorf("восстановление базы %s: %w", entry.Name, err)
+		}
+	}
+
+	if opts.Files {
+		if err := restoreAssets(opts, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findDatabase(m archive.Manifest, name string) (archive.ManifestDatabase, bool) {
+	for _, db := range m.Databases {
+		if db.Name == name {
+			return db, true
+		}
+	}
+	return archive.ManifestDatabase{}, false
+}
+
+func restoreFromArchive(ctx context.Context, opts Options, entry archive.ManifestDatabase) error {
+	tmpDir, err := os.MkdirTemp("", "tgdump-restore-")
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный каталог: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range []string{entry.Path, entry.Filtered} {
+		if name == "" {
+			continue
+		}
+		if _, err := archive.Extract(opts.Archive, name, filepath.Join(tmpDir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+	return RestoreDump(ctx, opts.Target, entry, tmpDir, DumpOptions{
+		DryRun: opts.DryRun,
+		Drop:   opts.Drop,
+		Jobs:   opts.Jobs,
+	})
+}
+
+type DumpOptions struct {
+	DryRun bool
+	Drop   bool
+	Jobs   int
+}
+
+// RestoreDump восстанавливает дамп из каталога dir (распакованный архив или
+// рабочий каталог запуска) в базу target.DBName.
+func RestoreDump(ctx context.Context, target config.DumpConfig, entry archive.ManifestDatabase, dir string, opts DumpOptions) error {
+	r := runner{dryRun: opts.DryRun, password: target.Password, passFile: target.PassFile}
+
+	if opts.Drop {
+		if err := r.maintenance(ctx, target, "DROP DATABASE IF EXISTS "+quoteIdent(target.DBName)); err != nil {
+			return err
+		}
+		if err := r.maintenance(ctx, target, "CREATE DATABASE "+quoteIdent(target.DBName)); err != nil {
+			return err
+		}
+	}
+
+	dumpPath := filepath.Join(dir, filepath.FromSlash(entry.Path))
+	format := config.NormalizeFormat(config.DumpFormat(entry.Format))
+	if format.IsPlain() {
+		return r.psql(ctx, target, "-f", dumpPath)
+	}
+
+	restoreArgs := []string{}
+	if opts.Jobs > 1 && format != config.FormatTar {
+		restoreArgs = append(restoreArgs, "--jobs", strconv.Itoa(opts.Jobs))
+	}
+	if entry.Filtered == "" {
+		return r.pgRestore(ctx, target, append(restoreArgs, dumpPath)...)
+	}
+
+	// Данные таблиц с исключёнными колонками лежат отдельно и загружаются
+	// до создания индексов и внешних ключей.
+	if err := r.pgRestore(ctx, target, append(restoreArgs, "--section=pre-data", "--section=data", dumpPath)...); err != nil {
+		return err
+	}
+	if err := r.psql(ctx, target, "-f", filepath.Join(dir, filepath.FromSlash(entry.Filtered))); err != nil {
+		return err
+	}
+	return r.pgRestore(ctx, target, append(restoreArgs, "--section=post-data", dumpPath)...)
+}
+
+// DropDatabase удаляет базу target.DBName, подключаясь к служебной базе postgres.
+func DropDatabase(ctx context.Context, target config.DumpConfig) error {
+	r := runner{password: target.Password, passFile: target.PassFile}
+	return r.maintenance(ctx, target, "DROP DATABASE IF EXISTS "+quoteIdent(target.DBName))
+}
+
+func restoreAssets(opts Options, m archive.Manifest) error {
+	assets := append(append([]archive.ManifestAsset{}, m.Files...), m.Directories...)
+	for _, asset := range assets {
+		rel := filepath.Clean(filepath.FromSlash(asset.Path))
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("путь %s выходит за пределы files_dir", asset.Path)
+		}
+		dst := filepath.Join(opts.FilesDir, rel)
+		if asset.Incremental {
+			log.Printf("%s: инкрементальная копия, архивы нужно применять по порядку начиная с полной копии %s", asset.Path, asset.Base)
+		}
+		if opts.DryRun {
+			log.Printf("[dry-run] %s -> %s", asset.Archive, dst)
+			for _, del := range asset.Deleted {
+				log.Printf("[dry-run] удаление %s", filepath.Join(dst, filepath.FromSlash(del)))
+			}
+			continue
+		}
+		n, err := archive.Extract(opts.Archive, asset.Archive, dst)
+		if err != nil && !(asset.Incremental && errors.Is(err, archive.ErrNotInArchive)) {
+			return fmt.Errorf("восстановление %s: %w", asset.Path, err)
+		}
+		log.Printf("восстановлено %s -> %s (%d файлов)", asset.Archive, dst, n)
+		if err := applyDeletions(dst, asset.Deleted); err != nil {
+			return fmt.Errorf("восстановление %s: %w", asset.Path, err)
+		}
+	}
+	return nil
+}
+
+// applyDeletions удаляет файлы, исчезнувшие из каталога к моменту инкремента.
+func applyDeletions(dst string, deleted []string) error {
+	for _, del := range deleted {
+		rel := filepath.FromSlash(del)
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("недопустимый путь в списке удалённых: %s", del)
+		}
+		if err := os.Remove(filepath.Join(dst, rel)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package retention
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tgdump/internal/config"
+)
+
+// TimestampLayout — формат имени архива в dump_dir.
+const TimestampLayout = "2006-01-02_15-04-05"
+
+type Archive struct {
+	Path string
+	Time time.Time
+	Size int64
+	Base time.Time // архив с полной копией, без которого этот не восстановить; нулевое — самодостаточен
+}
+
+// List находит архивы запусков (<prefix><timestamp>.zip и .zip.age) в dir;
+// время в именах трактуется в часовом поясе loc.
+func List(dir, prefix string, loc *time.Location) ([]Archive, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать %s: %w", dir, err)
+	}
+
+	var archives []Archive
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		stamp, ok := strings.CutSuffix(rest, ".zip")
+		if !ok {
+			stamp, ok = strings.CutSuffix(rest, ".zip.age")
+		}
+		if !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(TimestampLayout, stamp, loc)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, Archive{Path: filepath.Join(dir, name), Time: t, Size: info.Size()})
+	}
+	return archives, nil
+}
+
+// Select делит архивы на сохраняемые и удаляемые. Архив сохраняется, если он
+// среди keep_last последних или самый свежий в одном из daily/weekly/monthly
+// последних периодов. Затем старые архивы удаляются, пока общий размер больше
+// max_total_mb; самый свежий архив не удаляется никогда. Для каждого
+// сохранённого инкрементального архива сохраняется вся цепочка от Base, даже
+// если из-за этого превышен max_total_mb.
+func Select(archives []Archive, cfg config.RetentionConfig) (keep, prune []Archive) {
+	sorted := append([]Archive(nil), archives...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.After(sorted[j].Time) })
+
+	// Без правил по количеству ограничивается только общий размер.
+	keepAll := cfg.KeepLast == 0 && cfg.Daily == 0 && cfg.Weekly == 0 && cfg.Monthly == 0
+	kept := make([]bool, len(sorted))
+	for i := range sorted {
+		if keepAll || i < cfg.KeepLast {
+			kept[i] = true
+		}
+	}
+	markBuckets(sorted, kept, cfg.Daily, func(t time.Time) string { return t.Format("2006-01-02") })
+	markBuckets(sorted, kept, cfg.Weekly, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", y, w)
+	})
+	markBuckets(sorted, kept, cfg.Monthly, func(t time.Time) string { return t.Format("2006-01") })
+	if len(sorted) > 0 {
+		kept[0] = true
+	}
+
+	if cfg.MaxTotalMB > 0 {
+		limit := int64(cfg.MaxTotalMB) * 1024 * 1024
+		var total int64
+		for i, a := range sorted {
+			if kept[i] {
+				total += a.Size
+			}
+		}
+		for i := len(sorted) - 1; i > 0 && total > limit; i-- {
+			if kept[i] {
+				kept[i] = false
+				total -= sorted[i].Size
+			}
+		}
+	}
+
+	for i, a := range sorted {
+		if !kept[i] || a.Base.IsZero() {
+			continue
+		}
+		for j := i + 1; j < len(sorted) && !sorted[j].Time.Before(a.Base); j++ {
+			kept[j] = true
+		}
+	}
+
+	for i, a := range sorted {
+		if kept[i] {
+			keep = append(keep, a)
+		} else {
+			prune = append(prune, a)
+		}
+	}
+	return keep, prune
+}
+
+// markBuckets сохраняет самый свежий архив в каждом из n последних периодов.
+func markBuckets(sorted []Archive, kept []bool, n int, bucket func(time.Time) string) {
+	seen := make(map[string]struct{})
+	for i, a := range sorted {
+		if len(seen) >= n {
+			return
+		}
+		key := bucket(a.Time)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		kept[i] = true
+	}
+}
+
+// Apply удаляет архивы задания (с префиксом prefix) в dir по политике хранения
+// и возвращает удалённые. chains связывает метку инкрементального архива с
+// меткой архива его полной копии.
+func Apply(dir, prefix string, cfg config.RetentionConfig, loc *time.Location, chains map[string]string) ([]Archive, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	archives, err := List(dir, prefix, loc)
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range archives {
+		if base, ok := chains[a.Time.Format(TimestampLayout)]; ok {
+			archives[i].Base, err = time.ParseInLocation(TimestampLayout, base, loc)
+			if err != nil {
+				return nil, fmt.Errorf("некорректная метка полной копии %q: %w", base, err)
+			}
+		}
+	}
+	_, prune := Select(archives, cfg)
+
+	var removed []Archive
+	for _, a := range prune {
+		if err := os.Remove(a.Path); err != nil {
+			return removed, fmt.Errorf("не удалось удалить архив %s: %w", a.Path, err)
+		}
+		removed = append(removed, a)
+	}
+	return removed, nil
+}
@@ -0,0 +1,79 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"tgdump/internal/config"
+)
+
+func archivesEvery(start time.Time, step time.Duration, n int, size int64) []Archive {
+	var out []Archive
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * step)
+		out = append(out, Archive{Path: t.Format(TimestampLayout) + ".zip", Time: t, Size: size})
+	}
+	return out
+}
+
+func TestSelectGFS(t *testing.T) {
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	// 90 дней по два архива в день.
+	archives := archivesEvery(start, 12*time.Hour, 180, 1)
+
+	keep, prune := Select(archives, config.RetentionConfig{KeepLast: 2, Daily: 3, Weekly: 2, Monthly: 3})
+	if len(keep)+len(prune) != len(archives) {
+		t.Fatalf("keep %d + prune %d != %d", len(keep), len(prune), len(archives))
+	}
+	newest := archives[len(archives)-1]
+	if keep[0].Path != newest.Path {
+		t.Fatalf("newest not kept first: %s", keep[0].Path)
+	}
+	// Последний архив 2026-03-31 (вторник): 2 последних, ещё 30 и 29 марта,
+	// прошлая неделя уже закрыта воскресеньем 29 марта, плюс конец февраля и января.
+	if len(keep) != 6 {
+		t.Fatalf("unexpected keep count %d: %v", len(keep), keep)
+	}
+}
+
+func TestSelectSizeCap(t *testing.T) {
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	archives := archivesEvery(start, 24*time.Hour, 5, 600*1024)
+
+	keep, prune := Select(archives, config.RetentionConfig{MaxTotalMB: 1})
+	if len(keep) != 1 || len(prune) != 4 {
+		t.Fatalf("keep %d prune %d", len(keep), len(prune))
+	}
+	if keep[0].Path != archives[4].Path {
+		t.Fatalf("kept %s, want newest", keep[0].Path)
+	}
+
+	keep, _ = Select(archives, config.RetentionConfig{MaxTotalMB: 10})
+	if len(keep) != 5 {
+		t.Fatalf("size cap alone pruned under the limit: %d", len(keep))
+	}
+}
+
+func TestSelectKeepsChains(t *testing.T) {
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	archives := archivesEvery(start, 24*time.Hour, 6, 1)
+	// Полные копии 1 и 4 января, остальные — инкременты к ним.
+	for _, i := range []int{1, 2} {
+		archives[i].Base = archives[0].Time
+	}
+	archives[4].Base = archives[3].Time
+	archives[5].Base = archives[3].Time
+
+	keep, prune := Select(archives, config.RetentionConfig{KeepLast: 1})
+	if len(keep) != 3 || len(prune) != 3 {
+		t.Fatalf("keep %v prune %v", keep, prune)
+	}
+	if keep[2].Path != archives[3].Path {
+		t.Fatalf("полная копия цепочки удалена: keep %v", keep)
+	}
+
+	archives[3].Base = archives[0].Time
+	if keep, _ := Select(archives, config.RetentionConfig{KeepLast: 1, MaxTotalMB: 1}); len(keep) != 6 {
+		t.Fatalf("цепочка разорвана: keep %d", len(keep))
+	}
+}
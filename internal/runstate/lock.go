@@ -0,0 +1,146 @@
+package runstate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LockedError возвращается, если задание уже выполняется в этом или другом процессе.
+type LockedError struct {
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return "предыдущий запуск ещё выполняется"
+	}
+	return fmt.Sprintf("предыдущий запуск ещё выполняется (PID %d)", e.PID)
+}
+
+var (
+	heldMu sync.Mutex
+	held   = make(map[string]struct{})
+)
+
+// RunLock — блокировка запуска задания: файл с PID в служебном каталоге
+// dump_dir и отметка внутри процесса для пересекающихся срабатываний cron.
+type RunLock struct {
+	path string
+}
+
+func lockPath(dumpDir, job string) string {
+	name := "run.lock"
+	if job != "" {
+		name = "run_" + job + ".lock"
+	}
+	return filepath.Join(Dir(dumpDir), name)
+}
+
+// Lock захватывает блокировку задания. Файл блокировки, оставленный
+// завершившимся процессом, считается устаревшим и перезаписывается.
+func Lock(dumpDir, job string) (*RunLock, error) {
+	path := lockPath(dumpDir, job)
+
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	if _, ok := held[path]; ok {
+		return nil, &LockedError{PID: os.Getpid()}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог состояния: %w", err)
+	}
+	for attempt := 0; ; attempt++ {
+		err := createLockFile(path)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) || attempt > 0 {
+			return nil, fmt.Errorf("ошибка создания файла блокировки %s: %w", path, err)
+		}
+		pid, err := readLockPID(path)
+		if err != nil {
+			return nil, err
+		}
+		// Пустой файл только что создан другим процессом, который ещё не
+		// записал в него PID.
+		if pid == 0 && recentLockFile(path) {
+			return nil, &LockedError{}
+		}
+		// Свой PID в файле без отметки в held — остаток прошлого процесса
+		// с тем же PID (например, PID 1 в контейнере).
+		if pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			return nil, &LockedError{PID: pid}
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("не удалось удалить устаревший файл блокировки %s: %w", path, err)
+		}
+	}
+	held[path] = struct{}{}
+	return &RunLock{path: path}, nil
+}
+
+// Unlock освобождает блокировку.
+func (l *RunLock) Unlock() error {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	delete(held, l.path)
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("не удалось удалить файл блокировки %s: %w", l.path, err)
+	}
+	return nil
+}
+
+func createLockFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d\n", os.Getpid()); err != nil {
+		f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// readLockPID возвращает PID из файла блокировки; 0, если файл пуст или повреждён.
+func readLockPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения файла блокировки %s: %w", path, err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, nil
+	}
+	return pid, nil
+}
+
+// emptyLockAge — сколько пустой или повреждённый файл блокировки считается
+// занятым. PID записывается сразу после создания файла.
+const emptyLockAge = time.Minute
+
+func recentLockFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < emptyLockAge
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,78 @@
+package runstate
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := Lock(dir, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locked *LockedError
+	if _, err := Lock(dir, "db"); !errors.As(err, &locked) {
+		t.Fatalf("second lock: %v", err)
+	}
+	other, err := Lock(dir, "files")
+	if err != nil {
+		t.Fatalf("other job: %v", err)
+	}
+	_ = other.Unlock()
+
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	l, err = Lock(dir, "db")
+	if err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+	_ = l.Unlock()
+}
+
+func TestLockStale(t *testing.T) {
+	dir := t.TempDir()
+	path := lockPath(dir, "")
+	if err := os.MkdirAll(Dir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// PID, которого заведомо нет (больше pid_max в Linux).
+	if err := os.WriteFile(path, []byte(strconv.Itoa(1<<23)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Lock(dir, "")
+	if err != nil {
+		t.Fatalf("stale lock: %v", err)
+	}
+	_ = l.Unlock()
+}
+
+func TestLockEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := lockPath(dir, "")
+	if err := os.MkdirAll(Dir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var locked *LockedError
+	if _, err := Lock(dir, ""); !errors.As(err, &locked) {
+		t.Fatalf("fresh empty lock: %v", err)
+	}
+
+	old := time.Now().Add(-2 * emptyLockAge)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Lock(dir, "")
+	if err != nil {
+		t.Fatalf("old empty lock: %v", err)
+	}
+	_ = l.Unlock()
+}
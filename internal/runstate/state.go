@@ -0,0 +1,104 @@
+package runstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Dir возвращает служебный каталог в dump_dir для состояния между запусками.
+func Dir(dumpDir string) string {
+	return filepath.Join(dumpDir, ".state")
+}
+
+type lastRun struct {
+	LastSuccess time.Time `json:"last_success"`
+}
+
+func lastRunPath(dumpDir, job string) string {
+	name := "last_run.json"
+	if job != "" {
+		name = "last_run_" + job + ".json"
+	}
+	return filepath.Join(Dir(dumpDir), name)
+}
+
+// LastSuccess возвращает время последнего успешного запуска задания;
+// ok=false, если задание ещё ни разу не выполнялось успешно.
+func LastSuccess(dumpDir, job string) (t time.Time, ok bool, err error) {
+	data, err := os.ReadFile(lastRunPath(dumpDir, job))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("ошибка чтения состояния запусков: %w", err)
+	}
+	var st lastRun
+	if err := json.Unmarshal(data, &st); err != nil {
+		return time.Time{}, false, fmt.Errorf("ошибка разбора состояния запусков: %w", err)
+	}
+	return st.LastSuccess, !st.LastSuccess.IsZero(), nil
+}
+
+// RecordSuccess сохраняет время успешного запуска задания.
+func RecordSuccess(dumpDir, job string, t time.Time) error {
+	path := lastRunPath(dumpDir, job)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог состояния: %w", err)
+	}
+	data, err := json.Marshal(lastRun{LastSuccess: t})
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи состояния запусков: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func chainsPath(dumpDir, job string) string {
+	name := "chains.json"
+	if job != "" {
+		name = "chains_" + job + ".json"
+	}
+	return filepath.Join(Dir(dumpDir), name)
+}
+
+// Chains возвращает зависимости инкрементальных архивов задания: метка
+// времени архива -> метка архива с полной копией, без которого он не
+// восстанавливается.
+func Chains(dumpDir, job string) (map[string]string, error) {
+	data, err := os.ReadFile(chainsPath(dumpDir, job))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения цепочек архивов: %w", err)
+	}
+	chains := make(map[string]string)
+	if err := json.Unmarshal(data, &chains); err != nil {
+		return nil, fmt.Errorf("ошибка разбора цепочек архивов: %w", err)
+	}
+	return chains, nil
+}
+
+// SaveChains сохраняет зависимости инкрементальных архивов задания.
+func SaveChains(dumpDir, job string, chains map[string]string) error {
+	path := chainsPath(dumpDir, job)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог состояния: %w", err)
+	}
+	data, err := json.Marshal(chains)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи цепочек архивов: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
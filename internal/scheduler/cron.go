@@ -2,27 +2,62 @@ package scheduler
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
-// ScheduleDailyAt запускает функцию каждый день в указанное время (например, "08:00")
-func ScheduleDailyAt(timeStr string, job func()) {
-	c := cron.New()
+// ParseSpec разбирает расписание: "08:00" (каждый день в это время),
+// стандартное cron-выражение из пяти полей или дескриптор вроде "@every 6h".
+func ParseSpec(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(normalizeSpec(spec))
+}
 
-	// Парсим время: "08:00" → 0 0 8 * * *
-	parts := strings.Split(timeStr, ":")
-	if len(parts) != 2 {
-		panic("неправильный формат времени, должен быть HH:MM")
+// normalizeSpec переводит "HH:MM" в cron-выражение, остальное оставляет как есть.
+func normalizeSpec(spec string) string {
+	spec = strings.TrimSpace(spec)
+	hh, mm, ok := strings.Cut(spec, ":")
+	if !ok || strings.ContainsAny(spec, " @") {
+		return spec
 	}
-
-	spec := fmt.Sprintf("%s %s * * *", parts[1], parts[0]) // секунда, минута, час
-
-	_, err := c.AddFunc(spec, job)
-	if err != nil {
-		panic(fmt.Sprintf("не удалось добавить задачу в cron: %v", err))
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return spec
 	}
+	return fmt.Sprintf("%d %d * * *", m, h) // минута, час
+}
 
+// Start запускает job по каждому из расписаний в часовом поясе loc.
+func Start(specs []string, loc *time.Location, job func()) (*cron.Cron, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("не задано ни одного расписания")
+	}
+	c := cron.New(cron.WithLocation(loc))
+	for _, spec := range specs {
+		schedule, err := ParseSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное расписание %q: %w", spec, err)
+		}
+		c.Schedule(schedule, cron.FuncJob(job))
+	}
 	c.Start()
+	return c, nil
+}
+
+// Missed сообщает, было ли по какому-либо из расписаний запланировано
+// выполнение между last и now.
+func Missed(specs []string, loc *time.Location, last, now time.Time) (bool, error) {
+	for _, spec := range specs {
+		schedule, err := ParseSpec(spec)
+		if err != nil {
+			return false, fmt.Errorf("некорректное расписание %q: %w", spec, err)
+		}
+		if !schedule.Next(last.In(loc)).After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
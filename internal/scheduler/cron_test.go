@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	from := time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC) // вторник
+	cases := map[string]time.Time{
+		"08:00":     time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC),
+		"23:05":     time.Date(2026, 3, 10, 23, 5, 0, 0, time.UTC),
+		"0 * * * *": time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC),
+		"0 3 * * 0": time.Date(2026, 3, 15, 3, 0, 0, 0, time.UTC),
+		"@every 6h": from.Add(6 * time.Hour),
+		"@daily":    time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC),
+		" 8:00 ":    time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC),
+	}
+	for spec, want := range cases {
+		s, err := ParseSpec(spec)
+		if err != nil {
+			t.Fatalf("%q: %v", spec, err)
+		}
+		if got := s.Next(from); !got.Equal(want) {
+			t.Fatalf("%q: next %s, want %s", spec, got, want)
+		}
+	}
+	for _, spec := range []string{"", "25:00", "8", "* * *", "@every"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestMissed(t *testing.T) {
+	loc := time.UTC
+	last := time.Date(2026, 3, 10, 8, 0, 5, 0, loc)
+	specs := []string{"08:00"}
+
+	if missed, err := Missed(specs, loc, last, time.Date(2026, 3, 11, 7, 59, 0, 0, loc)); err != nil || missed {
+		t.Fatalf("before next run: %v %v", missed, err)
+	}
+	if missed, err := Missed(specs, loc, last, time.Date(2026, 3, 11, 9, 0, 0, 0, loc)); err != nil || !missed {
+		t.Fatalf("after missed run: %v %v", missed, err)
+	}
+	hourly := []string{"08:00", "0 * * * *"}
+	if missed, err := Missed(hourly, loc, last, time.Date(2026, 3, 10, 9, 30, 0, 0, loc)); err != nil || !missed {
+		t.Fatalf("hourly missed: %v %v", missed, err)
+	}
+}
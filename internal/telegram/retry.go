@@ -0,0 +1,114 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = 2 * time.Second
+	maxRetryDelay      = time.Minute
+)
+
+// APIError — ответ Bot API с ошибкой.
+type APIError struct {
+	StatusCode  int
+	Description string
+	RetryAfter  time.Duration // из parameters.retry_after при 429
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ошибка ответа Telegram: %d %s", e.StatusCode, e.Description)
+}
+
+func parseAPIError(status int, body []byte) *APIError {
+	var resp struct {
+		Description string `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	apiErr := &APIError{StatusCode: status}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Description == "" {
+		apiErr.Description = strings.TrimSpace(string(body))
+		return apiErr
+	}
+	apiErr.Description = resp.Description
+	apiErr.RetryAfter = time.Duration(resp.Parameters.RetryAfter) * time.Second
+	return apiErr
+}
+
+// do выполняет запрос с повторами: при 429 ждёт retry_after (не дольше
+// maxRetryDelay), при 5xx и сетевых ошибках — с экспоненциальной задержкой.
+// newRequest вызывается на каждую попытку, чтобы заново собрать тело запроса.
+// Отмена ctx прерывает и текущую загрузку, и ожидание повтора.
+func (c *Client) do(ctx context.Context, method string, newRequest func() (*http.Request, error)) error {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return err
+		}
+		err = c.roundTrip(req)
+		if err == nil {
+			return nil
+		}
+		delay, retry := c.retryDelay(err, attempt)
+		if !retry || attempt >= c.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("%s: %v, попытка %d из %d, повтор через %s", method, err, attempt, c.MaxAttempts, delay)
+		if c.sleep != nil {
+			c.sleep(delay)
+			continue
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) roundTrip(req *http.Request) error {
+	resp, err := telegramHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return parseAPIError(resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func (c *Client) retryDelay(err error, attempt int) (time.Duration, bool) {
+	backoff := c.BaseDelay << (attempt - 1)
+	if backoff <= 0 || backoff > maxRetryDelay {
+		backoff = maxRetryDelay
+	}
+	apiErr, ok := err.(*APIError)
+	switch {
+	case !ok:
+		return backoff, true
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		if apiErr.RetryAfter > 0 {
+			return min(apiErr.RetryAfter, maxRetryDelay), true
+		}
+		return backoff, true
+	case apiErr.StatusCode >= 500:
+		return backoff, true
+	default:
+		return 0, false
+	}
+}
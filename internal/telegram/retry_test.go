@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSendFileRetries(t *testing.T) {
+	var calls int
+	var docs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		f, _, err := r.FormFile("document")
+		if err != nil {
+			t.Errorf("document: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(f)
+		docs = append(docs, string(data))
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("bad gateway"))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	c := NewClient(srv.URL, "TOKEN")
+	c.BaseDelay = time.Second
+	c.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	path := filepath.Join(t.TempDir(), "a.zip")
+	if err := os.WriteFile(path, []byte("zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SendFileWithProgress(context.Background(), 1, path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(docs, []string{"zip", "zip", "zip"}) {
+		t.Fatalf("docs: %q", docs)
+	}
+	if want := []time.Duration{time.Second, 7 * time.Second}; !reflect.DeepEqual(delays, want) {
+		t.Fatalf("delays: %v, want %v", delays, want)
+	}
+}
+
+func TestSendMessageNoRetryOnClientError(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "TOKEN")
+	c.sleep = func(time.Duration) {}
+	err := c.SendMessage(context.Background(), "1", "hello")
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Description != "Bad Request: chat not found" || calls != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestRetryWaitCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"Too Many Requests","parameters":{"retry_after":3600}}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := NewClient(srv.URL, "TOKEN").SendMessage(ctx, "1", "hello")
+	if _, ok := err.(*APIError); !ok {
+		t.Fatalf("err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("ожидание повтора не прервано отменой: %s", elapsed)
+	}
+
+	c := NewClient(srv.URL, "TOKEN")
+	if d, _ := c.retryDelay(&APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}, 1); d != maxRetryDelay {
+		t.Fatalf("retry_after не ограничен: %s", d)
+	}
+}
@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -13,42 +14,69 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+)
 
-	"tgdump/internal/archive"
+const (
+	maxTelegramMessageLen = 4096
+	DefaultAPIURL         = "https://api.telegram.org"
 )
 
-const maxTelegramMessageLen = 4096
+// Client отправляет сообщения и файлы через Bot API: официальный или
+// собственный сервер telegram-bot-api.
+type Client struct {
+	APIURL string
+	Token  string
 
-// SendMessage отправляет текстовое сообщение в чат Telegram.
-func SendMessage(token, chatID, text string) error {
-	if len(text) > maxTelegramMessageLen {
-		text = text[:maxTelegramMessageLen-3] + "..."
-	}
+	MaxAttempts int           // попыток на запрос, включая первую
+	BaseDelay   time.Duration // задержка перед первым повтором, дальше удваивается
 
-	form := url.Values{
-		"chat_id": {chatID},
-		"text":    {text},
-	}
+	sleep func(time.Duration) // для тестов; nil — ожидание с учётом отмены ctx
+}
 
-	req, err := http.NewRequest(http.MethodPost,
-		"https://api.telegram.org/bot"+token+"/sendMessage",
-		strings.NewReader(form.Encode()))
-	if err != nil {
-		return fmt.Errorf("не удалось создать запрос: %w", err)
+func NewClient(apiURL, token string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return &Client{
+		APIURL:      strings.TrimSuffix(apiURL, "/"),
+		Token:       token,
+		MaxAttempts: defaultMaxAttempts,
+		BaseDelay:   defaultBaseDelay,
+	}
+}
 
-	resp, err := telegramHTTPClient().Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка отправки запроса: %w", err)
+// truncateText обрезает text до max символов, не разрезая UTF-8.
+func truncateText(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
 	}
-	defer resp.Body.Close()
+	runes := []rune(text)
+	return string(runes[:max-3]) + "..."
+}
 
-	respBody, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ошибка ответа Telegram: %s", string(respBody))
+func (c *Client) methodURL(method string) string {
+	return c.APIURL + "/bot" + c.Token + "/" + method
+}
+
+// SendMessage отправляет текстовое сообщение в чат Telegram.
+func (c *Client) SendMessage(ctx context.Context, chatID, text string) error {
+	text = truncateText(text, maxTelegramMessageLen)
+
+	form := url.Values{
+		"chat_id": {chatID},
+		"text":    {text},
 	}
-	return nil
+
+	body := form.Encode()
+	return c.do(ctx, "sendMessage", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.methodURL("sendMessage"), strings.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
 }
 
 func telegramHTTPClient() *http.Client {
@@ -61,40 +89,8 @@ func telegramHTTPClient() *http.Client {
 	}
 }
 
-// SendFolder архивирует каталог и отправляет zip в Telegram.
-// keepZip: сохранить zip на диске после отправки.
-func SendFolder(token, chatID, folderPath string, keepZip bool) error {
-	log.Printf("создание архива для отправки: %s", folderPath)
-	zipPath, err := archive.ZipDirectory(folderPath)
-	if err != nil {
-		return err
-	}
-	if !keepZip {
-		defer func() {
-			if err := os.Remove(zipPath); err != nil {
-				log.Printf("не удалось удалить временный архив: %v", err)
-			}
-		}()
-	}
-
-	zipInfo, err := os.Stat(zipPath)
-	if err != nil {
-		return fmt.Errorf("ошибка получения информации о файле: %w", err)
-	}
-	log.Printf("размер архива для отправки: %d bytes", zipInfo.Size())
-
-	log.Printf("отправка архива %s", zipPath)
-	if err := SendFile(token, chatID, zipPath); err != nil {
-		return fmt.Errorf("ошибка отправки архива: %w", err)
-	}
-	if keepZip {
-		log.Printf("архив сохранён: %s", zipPath)
-	}
-	return nil
-}
-
-// SendFile отправляет файл в чат Telegram.
-func SendFile(token, chatID, filePath string) error {
+// SendFile отправляет файл в чат Telegram; caption может быть пустым.
+func (c *Client) SendFile(ctx context.Context, chatID, filePath, caption string) error {
 	log.Printf("отправка файла %s", filePath)
 
 	chat, err := strconv.ParseInt(chatID, 10, 64)
@@ -102,7 +98,7 @@ func SendFile(token, chatID, filePath string) error {
 		return fmt.Errorf("ошибка конвертации chatID: %w", err)
 	}
 
-	err = SendFileWithProgress(token, chat, filePath)
+	err = c.SendFileWithProgress(ctx, chat, filePath, caption)
 	if err != nil {
 		return fmt.Errorf("ошибка отправки файла: %w", err)
 	}
@@ -131,16 +127,30 @@ func (pr *ProgressReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func SendFileWithProgress(token string, chatID int64, filePath string) error {
+// SendFileWithProgress отправляет файл документом. При повторе файл
+// открывается заново, а тело запроса собирается с начала.
+func (c *Client) SendFileWithProgress(ctx context.Context, chatID int64, filePath, caption string) error {
+	err := c.do(ctx, "sendDocument", func() (*http.Request, error) {
+		return c.newDocumentRequest(ctx, chatID, filePath, caption)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Println("Файл успешно отправлен.")
+	return nil
+}
+
+func (c *Client) newDocumentRequest(ctx context.Context, chatID int64, filePath, caption string) (*http.Request, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("не удалось открыть файл: %w", err)
+		return nil, fmt.Errorf("не удалось открыть файл: %w", err)
 	}
-	defer file.Close()
 
 	fileInfo, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("не удалось получить информацию о файле: %w", err)
+		file.Close()
+		return nil, fmt.Errorf("не удалось получить информацию о файле: %w", err)
 	}
 
 	pr := &ProgressReader{
@@ -154,11 +164,15 @@ func SendFileWithProgress(token string, chatID int64, filePath string) error {
 
 	// Пишем тело в фоне
 	go func() {
+		defer file.Close()
 		defer bodyWriter.Close()
 		defer multipartWriter.Close()
 
 		// Добавляем поле chat_id
 		_ = multipartWriter.WriteField("chat_id", strconv.FormatInt(chatID, 10))
+		if caption != "" {
+			_ = multipartWriter.WriteField("caption", caption)
+		}
 
 		// Добавляем файл
 		part, err := multipartWriter.CreateFormFile("document", filepath.Base(filePath))
@@ -174,23 +188,11 @@ func SendFileWithProgress(token string, chatID int64, filePath string) error {
 		}
 	}()
 
-	req, err := http.NewRequest("POST", "https://api.telegram.org/bot"+token+"/sendDocument", bodyReader)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.methodURL("sendDocument"), bodyReader)
 	if err != nil {
-		return fmt.Errorf("не удалось создать запрос: %w", err)
+		bodyReader.Close()
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
 	}
 	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
-
-	resp, err := telegramHTTPClient().Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка отправки запроса: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("ошибка ответа Telegram: %s", string(respBody))
-	}
-
-	log.Println("Файл успешно отправлен.")
-	return nil
+	return req, nil
 }
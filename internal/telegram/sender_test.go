@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestClientUsesAPIURL(t *testing.T) {
+	var gotPaths []string
+	var gotDoc, gotCaption string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPaths = append(gotPaths, r.URL.Path)
+		if r.URL.Path == "/botTOKEN/sendDocument" {
+			f, _, err := r.FormFile("document")
+			if err != nil {
+				t.Errorf("document: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(f)
+			gotDoc = string(data)
+			gotCaption = r.FormValue("caption")
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/", "TOKEN")
+	if err := c.SendMessage(context.Background(), "1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "a.zip")
+	if err := os.WriteFile(path, []byte("zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SendFile(context.Background(), "1", path, "part 1/1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gotPaths) != 2 || gotPaths[0] != "/botTOKEN/sendMessage" || gotPaths[1] != "/botTOKEN/sendDocument" {
+		t.Fatalf("paths: %v", gotPaths)
+	}
+	if gotDoc != "zip" || gotCaption != "part 1/1" {
+		t.Fatalf("document %q caption %q", gotDoc, gotCaption)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	text := strings.Repeat("ж", 5000)
+	got := truncateText(text, maxTelegramMessageLen)
+	if !utf8.ValidString(got) || utf8.RuneCountInString(got) != maxTelegramMessageLen || !strings.HasSuffix(got, "...") {
+		t.Fatalf("обрезано до %d символов, корректный UTF-8: %t", utf8.RuneCountInString(got), utf8.ValidString(got))
+	}
+	if short := "короткий"; truncateText(short, maxTelegramMessageLen) != short {
+		t.Fatal("короткий текст изменён")
+	}
+}
@@ -0,0 +1,23 @@
+package version
+
+import "runtime/debug"
+
+// Version задаётся при сборке: -ldflags "-X tgdump/internal/version.Version=v1.2.3".
+var Version = "dev"
+
+// String возвращает версию сборки; для dev-сборок — ревизию VCS, если она известна.
+func String() string {
+	if Version != "dev" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return Version + "-" + s.Value[:12]
+		}
+	}
+	return Version
+}